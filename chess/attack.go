@@ -0,0 +1,110 @@
+package chess
+
+/* Directions along which a bishop, and a rook, move */
+var diagonals = [4]int{+11, -9, -11, +9}
+var straights = [4]int{+10, +1, -10, -1}
+
+/*
+Attackers lists the squares of side's pieces that attack sq, whatever stands
+on it. A piece counts even if it is pinned, and a slider hidden behind
+another piece does not count until that piece moves away.
+*/
+func Attackers(b *Board, sq, side byte) []byte {
+	retval := make([]byte, 0, 4)
+	pawn := -10
+	if side == BLACK {
+		pawn = 10
+	}
+	for _, from := range [2]byte{byte(int(sq) + pawn - 1), byte(int(sq) + pawn + 1)} {
+		if b.Data[from] == side|PAWN {
+			retval = append(retval, from)
+		}
+	}
+	for dir := 0; dir < 8; dir++ {
+		if from := byte(int(sq) + Vector[KNIGHT][dir]); b.Data[from] == side|KNIGHT {
+			retval = append(retval, from)
+		}
+		if from := byte(int(sq) + Vector[KING][dir]); b.Data[from] == side|KING {
+			retval = append(retval, from)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		if from := firstpiece(b, sq, diagonals[i]); b.Data[from] == side|BISHOP ||
+			b.Data[from] == side|QUEEN {
+			retval = append(retval, from)
+		}
+		if from := firstpiece(b, sq, straights[i]); b.Data[from] == side|ROOK ||
+			b.Data[from] == side|QUEEN {
+			retval = append(retval, from)
+		}
+	}
+	return retval
+}
+
+/* firstpiece is the first square along step from sq that isn't empty,
+ * which may be off the board. */
+func firstpiece(b *Board, sq byte, step int) byte {
+	to := byte(int(sq) + step)
+	for b.Data[to] == EMPTY {
+		to = byte(int(to) + step)
+	}
+	return to
+}
+
+/* Attacks lists the squares the piece on from attacks: the empty ones it
+ * could move to, and those holding a piece of either side. Pawns only
+ * attack diagonally. */
+func Attacks(b *Board, from byte) []byte {
+	piece := GetPiece(b.Data[from])
+	retval := make([]byte, 0, 8)
+	if piece == PAWN {
+		pawn := 10
+		if IsBlack(b.Data[from]) {
+			pawn = -10
+		}
+		for _, to := range [2]byte{byte(int(from) + pawn - 1), byte(int(from) + pawn + 1)} {
+			if b.Data[to] != OFFBOARD {
+				retval = append(retval, to)
+			}
+		}
+		return retval
+	}
+	for dir := 0; dir < 8; dir++ {
+		step := Vector[piece][dir]
+		if step == 0 {
+			break
+		}
+		for to := byte(int(from) + step); b.Data[to] != OFFBOARD; to = byte(int(to) + step) {
+			retval = append(retval, to)
+			if !Slide[piece] || b.Data[to] != EMPTY {
+				break
+			}
+		}
+	}
+	return retval
+}
+
+/* AttackMap counts, for every square, how many pieces of each side attack
+ * it. */
+type AttackMap struct {
+	counts [2][120]int
+}
+
+func NewAttackMap(b *Board) *AttackMap {
+	m := new(AttackMap)
+	for _, from := range b.PieceList {
+		if !OnBoard(from) || GetPiece(b.Data[from]) == EMPTY {
+			continue
+		}
+		side := GetSide(b.Data[from]) >> 3
+		for _, to := range Attacks(b, from) {
+			m.counts[side][to]++
+		}
+	}
+	return m
+}
+
+/* Count is the number of side's pieces attacking sq. */
+func (m *AttackMap) Count(sq, side byte) int {
+	return m.counts[side>>3][sq]
+}
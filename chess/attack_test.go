@@ -0,0 +1,68 @@
+package chess
+
+import (
+	"testing"
+)
+
+const kiwipete = "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1"
+
+func squares(t *testing.T, names ...string) map[byte]bool {
+	retval := make(map[byte]bool)
+	for _, name := range names {
+		sq, err := AlgebraicToIndex(name)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		retval[sq] = true
+	}
+	return retval
+}
+
+func samesquares(t *testing.T, got []byte, want ...string) {
+	set := squares(t, want...)
+	if len(got) != len(set) {
+		t.Error(got, want)
+		return
+	}
+	for _, sq := range got {
+		if !set[sq] {
+			t.Error(IndexToAlgebraic(sq), want)
+		}
+	}
+}
+
+func TestAttackers(t *testing.T) {
+	board, _ := Parse(kiwipete)
+	e5, _ := AlgebraicToIndex("e5")
+	/* The bishop on g7 is stuck behind the knight */
+	samesquares(t, Attackers(board, e5, BLACK))
+	d5, _ := AlgebraicToIndex("d5")
+	samesquares(t, Attackers(board, d5, WHITE), "e4", "c3")
+	samesquares(t, Attackers(board, d5, BLACK), "e6", "b6", "f6")
+}
+
+func TestAttacks(t *testing.T) {
+	board, _ := Parse(kiwipete)
+	f3, _ := AlgebraicToIndex("f3")
+	samesquares(t, Attacks(board, f3), "e3", "d3", "c3", "g3", "h3", "f4",
+		"f5", "f6", "g4", "h5", "e4", "e2", "g2", "f2")
+	e4, _ := AlgebraicToIndex("e4")
+	samesquares(t, Attacks(board, e4), "d5", "f5")
+}
+
+func TestAttackMapAgreesWithSquareAttacked(t *testing.T) {
+	board, _ := Parse(kiwipete)
+	m := NewAttackMap(board)
+	for sq := A1; sq <= H8; sq++ {
+		if !OnBoard(sq) {
+			continue
+		}
+		for _, side := range []byte{WHITE, BLACK} {
+			count := m.Count(sq, side)
+			if count != len(Attackers(board, sq, side)) ||
+				(count > 0) != squareattacked(board, sq, side) {
+				t.Error(IndexToAlgebraic(sq), side, count)
+			}
+		}
+	}
+}
@@ -1,11 +1,12 @@
-package main
+/* Package chess holds the rules of the game: the board and its FEN form,
+ * move generation, making and unmaking moves, and zobrist hashing. */
+package chess
 
 import (
 	"errors"
 	"fmt"
 	"strconv"
 	"strings"
-	"time"
 	"unicode"
 )
 
@@ -48,13 +49,6 @@ const (
 	CASTLEBQ byte = 0x08
 )
 
-var (
-	Clock      time.Duration
-	TimeRepeat int
-	TimePerTC  time.Duration
-	TimeInc    time.Duration
-)
-
 type Board struct {
 	/* Mailbox style, 10x12 board. */
 	Data      [120]byte
@@ -63,8 +57,16 @@ type Board struct {
 	EnPassant byte
 	WhiteKing byte
 	BlackKing byte
-	Moves     int
 	PieceList [32]byte
+	/* Halfmoves since the last pawn move or capture, and the fullmove
+	 * number, as in fields 5 and 6 of a fen string. */
+	HalfMoveClock int
+	FullMoves     int
+	/* Zobrist key of the position, kept up to date by MakeMove */
+	Hash uint64
+	/* Keys of the positions before each move made on this board, for
+	 * spotting repetitions. */
+	History []uint64
 }
 
 const (
@@ -76,8 +78,14 @@ const START string = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
 
 const INVALID byte = 0
 
+/* CASTLEMASK is shared by every board, so it is set up once, before anyone
+ * can be using it. */
 var CASTLEMASK [120]byte
 
+func init() {
+	InitCastleMask()
+}
+
 func InitCastleMask() {
 	for sq := 0; sq < 120; sq++ {
 		CASTLEMASK[sq] = 15 // all castle rights const?
@@ -109,7 +117,6 @@ func InitCastleMask() {
 }
 
 func ClearBoard(b *Board) {
-	InitCastleMask()
 	b.EnPassant = 1
 	b.Castle = 0
 	var i byte
@@ -134,10 +141,23 @@ func fenfillboard(b *Board, field string) error {
 		if runeValue >= '1' && runeValue <= '8' {
 			inc, _ := strconv.Atoi(string(runeValue))
 			file += byte(inc)
+			if file > 8 {
+				return errors.New("Too many squares in a rank")
+			}
 		} else if runeValue == '/' {
+			if rank == 0 {
+				return errors.New("Too many ranks")
+			}
 			rank -= 1
 			file = 0
 		} else {
+			/* Anything more would land off the board or the piece list */
+			if file >= 8 {
+				return errors.New("Too many squares in a rank")
+			}
+			if idx >= 32 {
+				return errors.New("Too many pieces")
+			}
 			sq := CartesianToIndex(file, rank)
 			switch unicode.ToUpper(runeValue) {
 			case 'P':
@@ -212,10 +232,30 @@ func fenenpassant(b *Board, field string) {
 	}
 }
 
+func fencounters(b *Board, fields []string) error {
+	b.HalfMoveClock = 0
+	b.FullMoves = 1
+	if len(fields) > 0 {
+		halfmove, err := strconv.Atoi(fields[0])
+		if err != nil || halfmove < 0 {
+			return errors.New("Invalid halfmove clock")
+		}
+		b.HalfMoveClock = halfmove
+	}
+	if len(fields) > 1 {
+		fullmove, err := strconv.Atoi(fields[1])
+		if err != nil || fullmove < 1 {
+			return errors.New("Invalid fullmove number")
+		}
+		b.FullMoves = fullmove
+	}
+	return nil
+}
+
 func Parse(fen string) (*Board, error) {
 	b := new(Board)
 	ClearBoard(b)
-	fields := strings.Split(fen, " ")
+	fields := strings.Fields(fen)
 	if len(fields) < 4 {
 		return nil, errors.New("missing fields from fen string")
 	}
@@ -232,11 +272,78 @@ func Parse(fen string) (*Board, error) {
 		return nil, err
 	}
 	fenenpassant(b, fields[3])
+	err = fencounters(b, fields[4:])
+	if err != nil {
+		return nil, err
+	}
 	b.WhiteKing, _ = FindKing(b, WHITE)
 	b.BlackKing, _ = FindKing(b, BLACK)
+	b.Hash = HashBoard(b)
 	return b, nil
 }
 
+func fenboard(b *Board) string {
+	retval := ""
+	var rank, file byte
+	for rank = 7; rank != 255; rank-- {
+		empty := 0
+		for file = 0; file < 8; file++ {
+			sq := b.Data[CartesianToIndex(file, rank)]
+			if GetPiece(sq) == EMPTY {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				retval += strconv.Itoa(empty)
+				empty = 0
+			}
+			retval += ByteToString(sq)
+		}
+		if empty > 0 {
+			retval += strconv.Itoa(empty)
+		}
+		if rank > 0 {
+			retval += "/"
+		}
+	}
+	return retval
+}
+
+func fencastlestring(b *Board) string {
+	retval := ""
+	if b.Castle&CASTLEWK != 0 {
+		retval += "K"
+	}
+	if b.Castle&CASTLEWQ != 0 {
+		retval += "Q"
+	}
+	if b.Castle&CASTLEBK != 0 {
+		retval += "k"
+	}
+	if b.Castle&CASTLEBQ != 0 {
+		retval += "q"
+	}
+	if retval == "" {
+		return "-"
+	}
+	return retval
+}
+
+/* ToFEN is the inverse of Parse: Parse(ToFEN(b)) gives back the same
+ * position, counters included. */
+func ToFEN(b *Board) string {
+	who := "w"
+	if b.ToMove == BLACK {
+		who = "b"
+	}
+	ep := "-"
+	if OnBoard(b.EnPassant) {
+		ep = IndexToAlgebraic(b.EnPassant)
+	}
+	return fmt.Sprintf("%s %s %s %s %d %d", fenboard(b), who,
+		fencastlestring(b), ep, b.HalfMoveClock, b.FullMoves)
+}
+
 func PrintBoard(b *Board) string {
 	retval := ""
 	var rank, file byte
@@ -402,6 +509,107 @@ func CanCastle(b *Board, color, side byte) bool {
 	return b.Castle&flag != 0
 }
 
+/* Validate checks that a parsed position could come up in a game: one king
+ * each, no pawns on the first or last rank, and the side that just moved
+ * not left in check. */
+func Validate(b *Board) error {
+	kings := [2]int{}
+	var sq byte
+	for sq = A1; sq <= H8; sq++ {
+		if !OnBoard(sq) {
+			continue
+		}
+		piece := GetPiece(b.Data[sq])
+		if piece == KING {
+			kings[GetSide(b.Data[sq])/BLACK]++
+		} else if piece == PAWN && (sq/10 == 2 || sq/10 == 9) {
+			return errors.New("Pawn on the first or last rank")
+		}
+	}
+	if kings[0] != 1 || kings[1] != 1 {
+		return errors.New("Each side needs exactly one king")
+	}
+	if Illegal(b) {
+		return errors.New("The side not to move is in check")
+	}
+	if err := validcastling(b); err != nil {
+		return err
+	}
+	return validenpassant(b)
+}
+
+/* Where the king and rook for each castling right have to be */
+var castlehomes = [4]struct {
+	right, side, king, rook byte
+}{{CASTLEWK, WHITE, 25, 28}, {CASTLEWQ, WHITE, 25, 21},
+	{CASTLEBK, BLACK, 95, 98}, {CASTLEBQ, BLACK, 95, 91}}
+
+/* validcastling checks that the king and rook of every castling right are
+ * still at home. */
+func validcastling(b *Board) error {
+	for _, home := range castlehomes {
+		if b.Castle&home.right != 0 && (b.Data[home.king] != home.side|KING ||
+			b.Data[home.rook] != home.side|ROOK) {
+			return errors.New("Castling right without the king and rook at home")
+		}
+	}
+	return nil
+}
+
+/* validenpassant checks that the en passant square, if any, is one a pawn of
+ * the side that just moved can have passed over: empty, on the third rank
+ * from that side, with the pawn in front of it. */
+func validenpassant(b *Board) error {
+	if b.EnPassant == INVALID {
+		return nil
+	}
+	rank, pawn := byte(7), b.EnPassant-10
+	if b.ToMove == BLACK {
+		rank, pawn = 4, b.EnPassant+10
+	}
+	if !OnBoard(b.EnPassant) || b.EnPassant/10 != rank ||
+		b.Data[b.EnPassant] != EMPTY ||
+		b.Data[pawn] != (b.ToMove^BLACK)|PAWN {
+		return errors.New("Impossible en passant square")
+	}
+	return nil
+}
+
+/* SetToMove changes the side to move, keeping the key up to date. Any en
+ * passant square goes, since it belonged to the other side. */
+func SetToMove(b *Board, side byte) {
+	if b.ToMove == side {
+		return
+	}
+	b.Hash ^= zobristBlack ^ zobristEnPassant[b.EnPassant]
+	b.EnPassant = INVALID
+	b.ToMove = side
+}
+
+/* RepetitionCount is how many times the current position has occurred
+ * before. Only positions since the last capture or pawn move can repeat. */
+func RepetitionCount(b *Board) int {
+	count := 0
+	for i := 2; i <= b.HalfMoveClock && i <= len(b.History); i += 2 {
+		if b.History[len(b.History)-i] == b.Hash {
+			count++
+		}
+	}
+	return count
+}
+
+func IsRepetition(b *Board) bool {
+	return RepetitionCount(b) > 0
+}
+
+func IsThreefold(b *Board) bool {
+	return RepetitionCount(b) >= 2
+}
+
+func IsFiftyMoves(b *Board) bool {
+	return b.HalfMoveClock >= 100
+}
+
 func FindPiece(b *Board, target byte) (byte, error) {
 	for idx, sq := range b.PieceList {
 		if sq == target {
@@ -1,4 +1,4 @@
-package main
+package chess
 
 import (
 	"testing"
@@ -90,6 +90,75 @@ func TestParseInvalidData(t *testing.T) {
 	}
 }
 
+func TestParseTooManyPieces(t *testing.T) {
+	_, err := Parse("pppppppp/pppppppp/pppppppp/pppppppp/pppppppp/8/8/K6k w - - 0 1")
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestParseTooManyFiles(t *testing.T) {
+	for _, fen := range []string{"rnbqkbnrr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w - - 0 1",
+		"rnbqkbnr/pppppppp/9/8/8/8/PPPPPPPP/RNBQKBNR w - - 0 1",
+		"rnbqkbnr/pppppppp/44p/8/8/8/PPPPPPPP/RNBQKBNR w - - 0 1"} {
+		if _, err := Parse(fen); err == nil {
+			t.Error(fen)
+		}
+	}
+}
+
+func TestParseTooManyRanks(t *testing.T) {
+	_, err := Parse("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR/8 w - - 0 1")
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestValidateEnPassant(t *testing.T) {
+	good := []string{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1",
+		"4k3/8/8/8/3Pp3/8/8/4K3 b - d3 0 1"}
+	bad := []string{
+		/* No pawn has passed d5, and it is the wrong rank anyway */
+		"4k3/8/8/8/4P3/8/8/4K3 w - d5 0 1",
+		/* Right rank, but no pawn in front */
+		"4k3/8/8/4P3/8/8/8/4K3 w - d6 0 1",
+		/* The square isn't empty */
+		"4k3/8/3n4/3pP3/8/8/8/4K3 w - d6 0 1",
+		/* The pawn in front is our own */
+		"4k3/8/8/3PP3/8/8/8/4K3 w - d6 0 1",
+		/* Rank 6 with black to move */
+		"4k3/8/8/3p4/8/8/8/4K3 b - d6 0 1",
+	}
+	for _, fen := range good {
+		board, _ := Parse(fen)
+		if err := Validate(board); err != nil {
+			t.Error(fen, err)
+		}
+	}
+	for _, fen := range bad {
+		board, _ := Parse(fen)
+		if Validate(board) == nil {
+			t.Error(fen)
+		}
+	}
+}
+
+func TestValidateCastling(t *testing.T) {
+	board, _ := Parse(START)
+	if err := Validate(board); err != nil {
+		t.Error(err)
+	}
+	for _, fen := range []string{"4k3/8/8/8/8/8/8/4K3 w K - 0 1",
+		"r3k2r/8/8/8/8/8/8/R4K1R w Q - 0 1",
+		"1r2k2r/8/8/8/8/8/8/R3K2R w q - 0 1",
+		"r3k3/7r/8/8/8/8/8/R3K2R w k - 0 1"} {
+		board, _ := Parse(fen)
+		if Validate(board) == nil {
+			t.Error(fen)
+		}
+	}
+}
+
 func TestParseInvalidActiveColor(t *testing.T) {
 	_, err := Parse("rnbqkbnr/pp1ppppp/8/2p5/4P3/8/PPPP1PPP/RNBQKBNR o KQkq c6 0 2")
 	if err == nil {
@@ -118,6 +187,53 @@ func TestParseNoEnPassant(t *testing.T) {
 	}
 }
 
+func TestParseCounters(t *testing.T) {
+	board, err := Parse("rnbqkbnr/pp1ppppp/8/2p5/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2")
+	if err != nil {
+		t.FailNow()
+	}
+	if board.HalfMoveClock != 1 || board.FullMoves != 2 {
+		t.Fail()
+	}
+}
+
+func TestParseCountersDefault(t *testing.T) {
+	board, err := Parse("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -")
+	if err != nil {
+		t.FailNow()
+	}
+	if board.HalfMoveClock != 0 || board.FullMoves != 1 {
+		t.Fail()
+	}
+}
+
+func TestParseInvalidCounters(t *testing.T) {
+	_, err := Parse("rnbqkbnr/pp1ppppp/8/2p5/4P3/8/PPPP1PPP/RNBQKBNR w KQkq c6 x 2")
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestToFENRoundTrip(t *testing.T) {
+	fens := []string{
+		START,
+		"rnbqkbnr/pp1ppppp/8/2p5/4P3/8/PPPP1PPP/RNBQKBNR w KQkq c6 0 2",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 12 40",
+		"8/8/8/8/8/8/8/8 w Kq - 0 1",
+	}
+	for _, fen := range fens {
+		board, err := Parse(fen)
+		if err != nil {
+			t.FailNow()
+		}
+		if ToFEN(board) != fen {
+			t.Log(ToFEN(board), "!=", fen)
+			t.Fail()
+		}
+	}
+}
+
 func TestByteToStringGivenOffboard(t *testing.T) {
 	s := ByteToString(OFFBOARD)
 	if s != "" {
@@ -253,3 +369,88 @@ func TestAlgebraicToIndexInvalid(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func playmoves(t *testing.T, board *Board, moves ...string) {
+	for _, m := range moves {
+		move, err := ParseMove(board, m)
+		if err != nil {
+			t.Fatal(m, err)
+		}
+		MakeMove(board, move)
+	}
+}
+
+func TestRepetitionCount(t *testing.T) {
+	board, _ := Parse(START)
+	playmoves(t, board, "g1f3", "g8f6", "f3g1", "f6g8")
+	if RepetitionCount(board) != 1 || !IsRepetition(board) || IsThreefold(board) {
+		t.Fail()
+	}
+	playmoves(t, board, "g1f3", "g8f6", "f3g1", "f6g8")
+	if !IsThreefold(board) {
+		t.Fail()
+	}
+}
+
+func TestRepetitionResetByPawnMove(t *testing.T) {
+	board, _ := Parse(START)
+	playmoves(t, board, "g1f3", "g8f6", "f3g1", "f6g8", "e2e4",
+		"g8f6", "g1f3", "f6g8", "f3g1")
+	if IsRepetition(board) {
+		t.Fail()
+	}
+}
+
+func TestRepetitionUnmake(t *testing.T) {
+	board, _ := Parse(START)
+	playmoves(t, board, "g1f3", "g8f6", "f3g1")
+	move, _ := ParseMove(board, "f6g8")
+	undo := MakeMove(board, move)
+	UnmakeMove(board, move, undo)
+	if IsRepetition(board) || len(board.History) != 3 {
+		t.Fail()
+	}
+}
+
+func TestFiftyMoves(t *testing.T) {
+	board, _ := Parse("4k3/8/8/8/8/8/8/4K2R w - - 99 80")
+	if IsFiftyMoves(board) {
+		t.Fail()
+	}
+	playmoves(t, board, "h1h2")
+	if !IsFiftyMoves(board) {
+		t.Fail()
+	}
+}
+
+func TestValidateStart(t *testing.T) {
+	board, _ := Parse(START)
+	if Validate(board) != nil {
+		t.Fail()
+	}
+}
+
+func TestValidateMissingKing(t *testing.T) {
+	board, _ := Parse("8/8/8/3p4/4P3/8/8/4K3 w - - 0 1")
+	if Validate(board) == nil {
+		t.Fail()
+	}
+}
+
+func TestValidatePawnOnBackRank(t *testing.T) {
+	board, _ := Parse("4k2P/8/8/8/8/8/8/4K3 w - - 0 1")
+	if Validate(board) == nil {
+		t.Fail()
+	}
+}
+
+func TestValidateOpponentInCheck(t *testing.T) {
+	board, _ := Parse("4k3/8/8/8/8/8/8/4KR2 w - - 0 1")
+	if Validate(board) != nil {
+		t.Fail()
+	}
+	board, _ = Parse("4k3/8/8/8/8/8/8/4R1K1 w - - 0 1")
+	if Validate(board) == nil {
+		t.Fail()
+	}
+}
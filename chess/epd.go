@@ -0,0 +1,148 @@
+package chess
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+EPD is a position from an EPD record and the operations that came with it,
+each opcode with its operands. Quoted operands such as those of id and c0
+have the quotes taken off.
+*/
+type EPD struct {
+	Board *Board
+	Ops   map[string][]string
+}
+
+/* epdword splits the next word off line, stopping at a space or a ;. A word
+ * starting with a quote runs to the closing quote. */
+func epdword(line string) (string, string) {
+	line = strings.TrimLeft(line, " \t")
+	if strings.HasPrefix(line, `"`) {
+		if end := strings.IndexByte(line[1:], '"'); end >= 0 {
+			return line[1 : end+1], line[end+2:]
+		}
+		return line[1:], ""
+	}
+	end := strings.IndexAny(line, " \t;")
+	if end < 0 {
+		end = len(line)
+	}
+	return line[:end], line[end:]
+}
+
+/*
+ParseEPD reads an EPD record: the first four fields of a FEN string, then
+operations, each an opcode and its operands ended by a semicolon. The
+halfmove clock and fullmove number may be given as in FEN before the
+operations, as some files do, or with the hmvc and fmvn opcodes.
+*/
+func ParseEPD(line string) (*EPD, error) {
+	fields := make([]string, 4)
+	rest := strings.TrimSpace(line)
+	for i := range fields {
+		fields[i], rest = epdword(rest)
+	}
+	board, err := Parse(strings.Join(fields, " "))
+	if err != nil {
+		return nil, err
+	}
+	epd := &EPD{Board: board, Ops: make(map[string][]string)}
+	head := rest
+	if semi := strings.IndexByte(rest, ';'); semi >= 0 {
+		head = rest[:semi]
+	}
+	if counters := strings.Fields(head); len(counters) >= 2 {
+		_, err1 := strconv.Atoi(counters[0])
+		_, err2 := strconv.Atoi(counters[1])
+		if err1 == nil && err2 == nil {
+			if err := fencounters(board, counters[:2]); err != nil {
+				return nil, err
+			}
+			_, rest = epdword(rest)
+			_, rest = epdword(rest)
+		}
+	}
+	for {
+		rest = strings.TrimLeft(rest, " \t;")
+		if rest == "" {
+			break
+		}
+		var opcode string
+		opcode, rest = epdword(rest)
+		operands := []string{}
+		for {
+			rest = strings.TrimLeft(rest, " \t")
+			if rest == "" || rest[0] == ';' {
+				break
+			}
+			var operand string
+			operand, rest = epdword(rest)
+			operands = append(operands, operand)
+		}
+		epd.Ops[opcode] = operands
+	}
+	counters := []string{strconv.Itoa(board.HalfMoveClock), strconv.Itoa(board.FullMoves)}
+	for i, opcode := range []string{"hmvc", "fmvn"} {
+		if operands := epd.Ops[opcode]; len(operands) > 0 {
+			counters[i] = operands[0]
+		}
+	}
+	if err := fencounters(board, counters); err != nil {
+		return nil, err
+	}
+	return epd, nil
+}
+
+/* ReadEPD reads an EPD file, a record to a line. Blank lines, and lines
+ * starting with #, are passed over. */
+func ReadEPD(r io.Reader) ([]*EPD, error) {
+	var records []*EPD
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		epd, err := ParseEPD(line)
+		if err != nil {
+			return records, fmt.Errorf("line %d: %s", n, err)
+		}
+		records = append(records, epd)
+	}
+	return records, scanner.Err()
+}
+
+/* Op is the operands of opcode joined by spaces, or "" if there is no such
+ * operation. */
+func (e *EPD) Op(opcode string) string {
+	return strings.Join(e.Ops[opcode], " ")
+}
+
+/* Int is the operand of opcode, such as dm, acd or ce, as a number. */
+func (e *EPD) Int(opcode string) (int64, error) {
+	operands, ok := e.Ops[opcode]
+	if !ok || len(operands) == 0 {
+		return 0, errors.New("No " + opcode + " operation")
+	}
+	return strconv.ParseInt(operands[0], 10, 64)
+}
+
+/* Moves is the operands of opcode, such as bm or am, read as moves in SAN
+ * from the position. */
+func (e *EPD) Moves(opcode string) ([]Move, error) {
+	var moves []Move
+	for _, san := range e.Ops[opcode] {
+		move, err := ParseSAN(e.Board, san)
+		if err != nil {
+			return nil, err
+		}
+		moves = append(moves, *move)
+	}
+	return moves, nil
+}
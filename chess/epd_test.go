@@ -0,0 +1,90 @@
+package chess
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseEPD(t *testing.T) {
+	epd, err := ParseEPD(`2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001"; c0 "Qg6, and mate follows; Fritz";`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if epd.Op("id") != "WAC.001" || epd.Op("c0") != "Qg6, and mate follows; Fritz" {
+		t.Error(epd.Ops)
+	}
+	moves, err := epd.Moves("bm")
+	if err != nil || len(moves) != 1 || moves[0].String() != "g3g6" {
+		t.Error(moves, err)
+	}
+	if _, err := epd.Int("dm"); err == nil {
+		t.Error("dm found")
+	}
+	epd, err = ParseEPD("4k3/8/8/8/8/8/8/4K2R w K - bm O-O Rh8+; am Kd1; dm 5; acd 12; ce -32; hmvc 3; fmvn 40;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bm, _ := epd.Moves("bm"); len(bm) != 2 {
+		t.Error(bm)
+	}
+	if dm, err := epd.Int("dm"); dm != 5 || err != nil {
+		t.Error(dm, err)
+	}
+	if ce, err := epd.Int("ce"); ce != -32 || err != nil {
+		t.Error(ce, err)
+	}
+	if epd.Board.HalfMoveClock != 3 || epd.Board.FullMoves != 40 {
+		t.Error(ToFEN(epd.Board))
+	}
+}
+
+func TestParseEPDPerft(t *testing.T) {
+	/* The counters from FEN, and a ; before the first operation */
+	epd, err := ParseEPD(START + " ;D1 20 ;D2 400 ;D6 119060324")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ToFEN(epd.Board) != START {
+		t.Error(ToFEN(epd.Board))
+	}
+	if d6, err := epd.Int("D6"); d6 != 119060324 || err != nil {
+		t.Error(d6, err)
+	}
+}
+
+func TestReadEPD(t *testing.T) {
+	file := "# comment\n\n" + START[:len(START)-4] + " bm e4;\n8/8/8/8/8/8/8/8 x - - bm e4;\n"
+	records, err := ReadEPD(strings.NewReader(file))
+	if len(records) != 1 || err == nil || !strings.HasPrefix(err.Error(), "line 4:") {
+		t.Error(len(records), err)
+	}
+}
+
+func TestPerftSuite(t *testing.T) {
+	file, err := os.Open("testdata/perftsuite.epd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	suite, err := ReadEPD(file)
+	if err != nil || len(suite) != 6 {
+		t.Fatal(len(suite), err)
+	}
+	maxdepth := 3
+	if testing.Short() {
+		maxdepth = 2
+	}
+	for _, position := range suite {
+		for depth := 1; depth <= maxdepth; depth++ {
+			want, err := position.Int(fmt.Sprintf("D%d", depth))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Perft(depth, position.Board, false); got != uint64(want) {
+				t.Error(ToFEN(position.Board), depth, got, want)
+			}
+		}
+	}
+}
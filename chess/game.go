@@ -0,0 +1,126 @@
+package chess
+
+import (
+	"errors"
+	"time"
+)
+
+/* Ply is one move of a game, with what it takes to take it back. */
+type Ply struct {
+	Move Move
+	Undo *Undo
+	/* Time left on the mover's clock after the move, or 0 if unknown */
+	Clock time.Duration
+	/* What the player had to say about it, such as an engine's score */
+	Comment string
+}
+
+/*
+Game is the record of a game being played: where it started, the moves made
+since, and the tags and result that go with it. Board is the position after
+the first Current moves of Plies. Moves that have been taken back stay on the
+end of Plies so they can be made again, until another move is played in
+their place and the game branches off from there.
+*/
+type Game struct {
+	Start   *Board
+	Board   *Board
+	Plies   []Ply
+	Current int
+	Tags    map[string]string
+	Result  string
+}
+
+/* NewGame starts a game from start, which is copied. */
+func NewGame(start *Board) *Game {
+	first := *start
+	first.History = append([]uint64(nil), start.History...)
+	board := first
+	board.History = append([]uint64(nil), first.History...)
+	return &Game{Start: &first, Board: &board, Tags: make(map[string]string),
+		Result: "*"}
+}
+
+/* Play makes move, which must be legal, and records it. If it is the move
+ * that was taken back last, the moves after it are kept too; otherwise they
+ * are forgotten. Either way the clock and comment are the new move's, which
+ * has none yet. */
+func (g *Game) Play(move *Move) {
+	if g.Current < len(g.Plies) && SameMove(&g.Plies[g.Current].Move, move) {
+		g.Plies[g.Current].Clock = 0
+		g.Plies[g.Current].Comment = ""
+		g.Redo()
+		return
+	}
+	g.Plies = append(g.Plies[:g.Current], Ply{Move: *move})
+	g.Plies[g.Current].Undo = MakeMove(g.Board, &g.Plies[g.Current].Move)
+	g.Current++
+}
+
+/* SetClock notes the time left on the clock of the side that made the last
+ * move. */
+func (g *Game) SetClock(clock time.Duration) {
+	if g.Current > 0 {
+		g.Plies[g.Current-1].Clock = clock
+	}
+}
+
+/* Undo takes back the last move, if there is one. */
+func (g *Game) Undo() bool {
+	if g.Current == 0 {
+		return false
+	}
+	g.Current--
+	ply := &g.Plies[g.Current]
+	UnmakeMove(g.Board, &ply.Move, ply.Undo)
+	return true
+}
+
+/* Redo makes the next move that was taken back, if there is one. */
+func (g *Game) Redo() bool {
+	if g.Current == len(g.Plies) {
+		return false
+	}
+	ply := &g.Plies[g.Current]
+	ply.Undo = MakeMove(g.Board, &ply.Move)
+	g.Current++
+	return true
+}
+
+/* GoTo takes back or remakes moves until ply of them have been made. */
+func (g *Game) GoTo(ply int) error {
+	if ply < 0 || ply > len(g.Plies) {
+		return errors.New("No such ply")
+	}
+	for g.Current > ply {
+		g.Undo()
+	}
+	for g.Current < ply {
+		g.Redo()
+	}
+	return nil
+}
+
+/* Moves lists the moves made to reach the current position. */
+func (g *Game) Moves() []Move {
+	moves := make([]Move, g.Current)
+	for i := range moves {
+		moves[i] = g.Plies[i].Move
+	}
+	return moves
+}
+
+/* Branch is a new game with the same start, tags and moves up to the
+ * current position, which can be played on without touching this one. */
+func (g *Game) Branch() *Game {
+	branch := NewGame(g.Start)
+	for key, value := range g.Tags {
+		branch.Tags[key] = value
+	}
+	for _, ply := range g.Plies[:g.Current] {
+		branch.Play(&ply.Move)
+		branch.SetClock(ply.Clock)
+		branch.Plies[branch.Current-1].Comment = ply.Comment
+	}
+	return branch
+}
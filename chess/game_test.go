@@ -0,0 +1,96 @@
+package chess
+
+import (
+	"testing"
+	"time"
+)
+
+func playsan(t *testing.T, g *Game, sans ...string) {
+	for _, san := range sans {
+		move, err := ParseSAN(g.Board, san)
+		if err != nil {
+			t.Fatal(san, err)
+		}
+		g.Play(move)
+	}
+}
+
+func TestGameUndoRedo(t *testing.T) {
+	start, _ := Parse(START)
+	g := NewGame(start)
+	playsan(t, g, "e4", "e5", "Nf3")
+	after := ToFEN(g.Board)
+	if !g.Undo() || !g.Undo() || g.Current != 1 || len(g.Plies) != 3 {
+		t.Fatal("undo", g.Current, len(g.Plies))
+	}
+	/* Playing the move that was taken back keeps the rest of the line */
+	playsan(t, g, "e5")
+	if !g.Redo() || ToFEN(g.Board) != after || g.Redo() {
+		t.Error("redo", ToFEN(g.Board))
+	}
+	if err := g.GoTo(0); err != nil || ToFEN(g.Board) != START || g.Undo() {
+		t.Error("goto 0", ToFEN(g.Board))
+	}
+	if g.GoTo(4) == nil {
+		t.Error("went past the end")
+	}
+	if g.GoTo(3); ToFEN(g.Board) != after || len(g.Moves()) != 3 {
+		t.Error("goto 3", ToFEN(g.Board))
+	}
+}
+
+func TestGamePlayAfterUndo(t *testing.T) {
+	start, _ := Parse(START)
+	g := NewGame(start)
+	playsan(t, g, "e4")
+	g.SetClock(time.Minute)
+	g.Plies[0].Comment = "+0.30/10"
+	g.Undo()
+	/* The same move played again is a new move, with nothing said yet */
+	playsan(t, g, "e4")
+	if g.Plies[0].Clock != 0 || g.Plies[0].Comment != "" {
+		t.Error(g.Plies[0])
+	}
+	/* Redo, on the other hand, brings the old one back as it was */
+	g.SetClock(time.Minute)
+	g.Undo()
+	if !g.Redo() || g.Plies[0].Clock != time.Minute {
+		t.Error(g.Plies[0])
+	}
+}
+
+func TestGameBranch(t *testing.T) {
+	start, _ := Parse(START)
+	g := NewGame(start)
+	playsan(t, g, "e4", "e5", "Nf3", "Nc6")
+	g.GoTo(2)
+	branch := g.Branch()
+	playsan(t, g, "Bc4")
+	if len(g.Plies) != 3 {
+		t.Error("old line kept", len(g.Plies))
+	}
+	playsan(t, branch, "d4")
+	if g.Plies[2].Move.String() != "f1c4" || branch.Plies[2].Move.String() != "d2d4" {
+		t.Error(g.Moves(), branch.Moves())
+	}
+	if ToFEN(start) != START || ToFEN(g.Start) != START {
+		t.Error("start changed")
+	}
+}
+
+func TestGameRepetition(t *testing.T) {
+	start, _ := Parse(START)
+	g := NewGame(start)
+	playsan(t, g, "Nf3", "Nf6", "Ng1", "Ng8", "Nf3", "Nf6", "Ng1")
+	if IsThreefold(g.Board) {
+		t.Error("threefold too soon")
+	}
+	playsan(t, g, "Ng8")
+	if !IsThreefold(g.Board) {
+		t.Error("no threefold")
+	}
+	g.Undo()
+	if IsThreefold(g.Board) {
+		t.Error("threefold after undo")
+	}
+}
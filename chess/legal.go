@@ -0,0 +1,224 @@
+package chess
+
+/*
+checkinfo is what LegalMoves needs to know about the king of the side to
+move before it looks at any moves: how many pieces give check, which squares
+would stop a single check (the checker's own square, and those in between if
+it is a slider), and which of our pieces are pinned, and along which ray.
+*/
+type checkinfo struct {
+	king     byte
+	checkers int
+	block    [120]bool
+	pinned   [120]int
+}
+
+func getcheckinfo(b *Board) *checkinfo {
+	info := &checkinfo{king: b.WhiteKing}
+	if b.ToMove == BLACK {
+		info.king = b.BlackKing
+	}
+	enemy := b.ToMove ^ BLACK
+	for dir := 0; dir < 8; dir++ {
+		step := Vector[QUEEN][dir]
+		/* Rooks move along ranks and files, bishops diagonally */
+		slider := BISHOP
+		if step == 10 || step == -10 || step == 1 || step == -1 {
+			slider = ROOK
+		}
+		var own byte = INVALID
+		for to := byte(int(info.king) + step); b.Data[to] != OFFBOARD; to = byte(int(to) + step) {
+			piece := GetPiece(b.Data[to])
+			if piece == EMPTY {
+				continue
+			}
+			if GetSide(b.Data[to]) == b.ToMove {
+				if own != INVALID {
+					break
+				}
+				own = to
+				continue
+			}
+			if piece == QUEEN || piece == slider {
+				if own != INVALID {
+					info.pinned[own] = step
+				} else {
+					info.checkers++
+					for sq := to; sq != info.king; sq = byte(int(sq) - step) {
+						info.block[sq] = true
+					}
+				}
+			}
+			break
+		}
+		to := byte(int(info.king) + Vector[KNIGHT][dir])
+		if b.Data[to] != OFFBOARD && b.Data[to] == enemy|KNIGHT {
+			info.checkers++
+			info.block[to] = true
+		}
+	}
+	pawn := 10
+	if b.ToMove == BLACK {
+		pawn = -10
+	}
+	for _, side := range []int{-1, 1} {
+		to := byte(int(info.king) + pawn + side)
+		if b.Data[to] == enemy|PAWN {
+			info.checkers++
+			info.block[to] = true
+		}
+	}
+	return info
+}
+
+/* onpin says whether a piece pinned along step can go to to: only squares
+ * on the same ray from the king, up to and including the pinner, will do. */
+func onpin(b *Board, king byte, step int, to byte) bool {
+	for sq := byte(int(king) + step); b.Data[sq] != OFFBOARD; sq = byte(int(sq) + step) {
+		if sq == to {
+			return true
+		}
+		if GetPiece(b.Data[sq]) != EMPTY && GetSide(b.Data[sq]) != b.ToMove {
+			break
+		}
+	}
+	return false
+}
+
+/* kingsafe says whether the king can step to to. The king is taken off the
+ * board while we look, so that it can't hide behind itself from a slider. */
+func kingsafe(b *Board, king, to byte) bool {
+	data := b.Data[king]
+	b.Data[king] = EMPTY
+	safe := !squareattacked(b, to, b.ToMove^BLACK)
+	b.Data[king] = data
+	return safe
+}
+
+/* legal says whether a move from MoveGen leaves our king safe, without
+ * making it. En passant can uncover a check along the rank through two
+ * pieces at once, so that one is left to MakeMove and Illegal. */
+func legal(b *Board, info *checkinfo, move *Move) bool {
+	if move.From == info.king {
+		/* Castling has been checked for attacks already */
+		return move.Kind == MoveCastle || kingsafe(b, info.king, move.To)
+	}
+	if move.Kind == MoveEnPassant {
+		undo := MakeMove(b, move)
+		illegal := Illegal(b)
+		UnmakeMove(b, move, undo)
+		return !illegal
+	}
+	if info.checkers > 1 || (info.checkers == 1 && !info.block[move.To]) {
+		return false
+	}
+	step := info.pinned[move.From]
+	return step == 0 || onpin(b, info.king, step, move.To)
+}
+
+/* LegalMoves is MoveGen with the moves that would leave the king in check
+ * taken out. Pins and checks are worked out once for the whole position,
+ * so hardly any moves have to be made to be tested. */
+func LegalMoves(b *Board) []Move {
+	if !haveking(b) {
+		/* No king to look after: go the long way round */
+		return filterlegal(b, MoveGen(b))
+	}
+	info := getcheckinfo(b)
+	if info.checkers > 0 {
+		return evasions(b, info)
+	}
+	return keep(MoveGen(b), 0, func(m *Move) bool {
+		return legal(b, info, m)
+	})
+}
+
+/* Evasions are the legal moves when in check. Only the king can answer a
+ * double check; a single one can also be answered by taking the checker or,
+ * if it is a slider, getting in its way. The moves are those LegalMoves
+ * would give, generated without looking at the rest. */
+func Evasions(b *Board) []Move {
+	if !haveking(b) {
+		return filterlegal(b, MoveGen(b))
+	}
+	return evasions(b, getcheckinfo(b))
+}
+
+func evasions(b *Board, info *checkinfo) []Move {
+	retval := make([]Move, 0, 16)
+	retval = quietmove(b, info.king, retval)
+	retval = keep(retval, 0, func(m *Move) bool {
+		return kingsafe(b, info.king, m.To)
+	})
+	if info.checkers > 1 {
+		return retval
+	}
+	for _, i := range b.PieceList {
+		if !OnBoard(i) || i == info.king || GetPiece(b.Data[i]) == EMPTY ||
+			GetSide(b.Data[i]) != b.ToMove {
+			continue
+		}
+		/* A pinned piece can't leave the pin to deal with a check
+		 * coming from another direction. */
+		if info.pinned[i] != 0 {
+			continue
+		}
+		start := len(retval)
+		if GetPiece(b.Data[i]) == PAWN {
+			retval = pawnmove(b, i, retval)
+		} else {
+			retval = quietmove(b, i, retval)
+		}
+		retval = keep(retval, start, func(m *Move) bool {
+			return legal(b, info, m)
+		})
+	}
+	return retval
+}
+
+/* keep drops the moves from start on that good says no to. */
+func keep(moves []Move, start int, good func(*Move) bool) []Move {
+	kept := moves[:start]
+	for i := start; i < len(moves); i++ {
+		if good(&moves[i]) {
+			kept = append(kept, moves[i])
+		}
+	}
+	return kept
+}
+
+func haveking(b *Board) bool {
+	king, err := GetKing(b, b.ToMove)
+	return err == nil && b.Data[king] == b.ToMove|KING
+}
+
+func filterlegal(b *Board, moves []Move) []Move {
+	legalmoves := moves[:0]
+	for _, move := range moves {
+		undo := MakeMove(b, &move)
+		if !Illegal(b) {
+			legalmoves = append(legalmoves, move)
+		}
+		UnmakeMove(b, &move, undo)
+	}
+	return legalmoves
+}
+
+/* PerftLegal counts the leaf nodes depth plies down like Perft, but using
+ * LegalMoves, so the last ply is counted without making the moves. */
+func PerftLegal(depth int, board *Board) uint64 {
+	if depth == 0 {
+		return 1
+	}
+	moves := LegalMoves(board)
+	if depth == 1 {
+		return uint64(len(moves))
+	}
+	var nodes uint64
+	for _, move := range moves {
+		undo := MakeMove(board, &move)
+		nodes += PerftLegal(depth-1, board)
+		UnmakeMove(board, &move, undo)
+	}
+	return nodes
+}
@@ -1,4 +1,4 @@
-package main
+package chess
 
 import (
 	"errors"
@@ -37,10 +37,12 @@ type Move struct {
 }
 
 type Undo struct {
-	ToData    byte
-	EnPassant byte
-	Castle    byte
-	Index     byte
+	ToData        byte
+	EnPassant     byte
+	Castle        byte
+	Index         byte
+	HalfMoveClock int
+	Hash          uint64
 }
 
 func pawnmove(b *Board, i byte, retval []Move) []Move {
@@ -242,7 +244,21 @@ func MoveGen(b *Board) []Move {
 }
 
 func MakeMove(b *Board, m *Move) *Undo {
-	retval := &Undo{b.Data[m.To], b.EnPassant, b.Castle, OFFBOARD}
+	retval := &Undo{b.Data[m.To], b.EnPassant, b.Castle, OFFBOARD,
+		b.HalfMoveClock, b.Hash}
+	b.History = append(b.History, b.Hash)
+	/* Take out everything that might change; it goes back in below. */
+	b.Hash ^= zobristCastle[b.Castle&0x0F] ^ zobristEnPassant[b.EnPassant]
+	b.Hash ^= zobristsquare(b, m.From) ^ zobristsquare(b, m.To)
+	if GetPiece(b.Data[m.From]) == PAWN || GetPiece(b.Data[m.To]) != EMPTY ||
+		m.Kind == MoveEnPassant {
+		b.HalfMoveClock = 0
+	} else {
+		b.HalfMoveClock++
+	}
+	if b.ToMove == BLACK {
+		b.FullMoves++
+	}
 	if GetPiece(b.Data[m.From]) == KING {
 		if b.ToMove == BLACK {
 			b.BlackKing = m.To
@@ -269,13 +285,19 @@ func MakeMove(b *Board, m *Move) *Undo {
 			b.EnPassant = m.From + 10
 		}
 	case MoveEnPassant:
+		victim := m.To - 10
 		if b.ToMove == BLACK {
-			retval.Index, _ = FindPiece(b, m.To+10)
-			b.Data[m.To+10] = EMPTY
-		} else {
-			retval.Index, _ = FindPiece(b, m.To-10)
-			b.Data[m.To-10] = EMPTY
+			victim = m.To + 10
 		}
+		var err error
+		retval.Index, err = FindPiece(b, victim)
+		if err != nil {
+			/* Only a board that never got past Validate can get here */
+			panic("MakeMove: no pawn to take en passant on " +
+				IndexToAlgebraic(victim))
+		}
+		b.Hash ^= zobristsquare(b, victim)
+		b.Data[victim] = EMPTY
 		b.PieceList[retval.Index] = OFFBOARD
 	case MoveCapPromote:
 		fallthrough
@@ -286,18 +308,28 @@ func MakeMove(b *Board, m *Move) *Undo {
 			/* Queenside */
 			retval.Index, _ = FindPiece(b, m.To-2)
 			b.PieceList[retval.Index] = m.To + 1
+			b.Hash ^= zobristsquare(b, m.To-2)
 			b.Data[m.To+1] = b.Data[m.To-2]
 			b.Data[m.To-2] = EMPTY
+			b.Hash ^= zobristsquare(b, m.To+1)
 		} else {
 			/* Kingside */
 			retval.Index, _ = FindPiece(b, m.To+1)
 			b.PieceList[retval.Index] = m.To - 1
+			b.Hash ^= zobristsquare(b, m.To+1)
 			b.Data[m.To-1] = b.Data[m.To+1]
 			b.Data[m.To+1] = EMPTY
+			b.Hash ^= zobristsquare(b, m.To-1)
 		}
 	}
 	b.Castle &= CASTLEMASK[m.From] & CASTLEMASK[m.To]
 	b.ToMove ^= BLACK
+	b.Hash ^= zobristsquare(b, m.To)
+	b.Hash ^= zobristCastle[b.Castle&0x0F] ^ zobristEnPassant[b.EnPassant]
+	b.Hash ^= zobristBlack
+	if DebugHash {
+		debugcheckhash(b, "MakeMove")
+	}
 	return retval
 }
 
@@ -308,7 +340,13 @@ func UnmakeMove(b *Board, m *Move, u *Undo) {
 	b.PieceList[idx] = m.From
 	b.EnPassant = u.EnPassant
 	b.Castle = u.Castle
+	b.HalfMoveClock = u.HalfMoveClock
+	b.Hash = u.Hash
+	b.History = b.History[:len(b.History)-1]
 	b.ToMove ^= BLACK
+	if b.ToMove == BLACK {
+		b.FullMoves--
+	}
 	switch m.Kind {
 	case MoveCapture:
 		b.PieceList[u.Index] = m.To
@@ -345,11 +383,19 @@ func UnmakeMove(b *Board, m *Move, u *Undo) {
 			b.WhiteKing = m.From
 		}
 	}
+	if DebugHash {
+		debugcheckhash(b, "UnmakeMove")
+	}
+}
+
+/* SameMove compares two moves, ignoring their ordering scores. */
+func SameMove(a, b *Move) bool {
+	return a.From == b.From && a.To == b.To && a.Promote == b.Promote
 }
 
+/* String is the move in long algebraic; MoveToSAN needs the board too. */
 func (m Move) String() string {
-	return fmt.Sprint("{From: ", IndexToAlgebraic(m.From), " to: ",
-		IndexToAlgebraic(m.To), " type: ", m.Kind, "}")
+	return MoveToLongAlgebraic(&m)
 }
 
 func DoDividePerft(depth int) uint64 {
@@ -401,15 +447,23 @@ func MoveToLongAlgebraic(move *Move) string {
 }
 
 func ParseMove(b *Board, m string) (*Move, error) {
-	moves := MoveGen(b)
-	for _, move := range moves {
+	for _, move := range LegalMoves(b) {
 		if MoveToLongAlgebraic(&move) == m {
 			return &move, nil
 		}
 	}
+	for _, move := range MoveGen(b) {
+		if MoveToLongAlgebraic(&move) == m {
+			return nil, errors.New("Move leaves the king in check")
+		}
+	}
 	return nil, errors.New("Move not legal")
 }
 
+func CountLegal(b *Board) int {
+	return len(LegalMoves(b))
+}
+
 func FilterCaptures(movelist []Move) []Move {
 	captures := make([]Move, 0, 32)
 	for _, move := range movelist {
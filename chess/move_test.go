@@ -1,4 +1,4 @@
-package main
+package chess
 
 import (
 	"testing"
@@ -380,6 +380,31 @@ func TestUnmakeMoveBlackEnPassant(t *testing.T) {
 	}
 }
 
+func TestMakeMoveCounters(t *testing.T) {
+	board, err := Parse("rnbqkbnr/pp1ppppp/8/2p5/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2")
+	if err != nil {
+		t.FailNow()
+	}
+	from, _ := AlgebraicToIndex("b8")
+	to, _ := AlgebraicToIndex("c6")
+	move := &Move{from, to, MoveQuiet, EMPTY, 0}
+	undo := MakeMove(board, move)
+	if board.HalfMoveClock != 2 || board.FullMoves != 3 {
+		t.Fail()
+	}
+	UnmakeMove(board, move, undo)
+	if ToFEN(board) != "rnbqkbnr/pp1ppppp/8/2p5/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2" {
+		t.Fail()
+	}
+	from, _ = AlgebraicToIndex("d7")
+	to, _ = AlgebraicToIndex("d6")
+	move = &Move{from, to, MoveQuiet, EMPTY, 0}
+	MakeMove(board, move)
+	if board.HalfMoveClock != 0 || board.FullMoves != 3 {
+		t.Fail()
+	}
+}
+
 func TestPerftPawnPromotions(t *testing.T) {
 	board, err := Parse("n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1")
 	if err != nil {
@@ -457,3 +482,103 @@ func TestFilterCapturesOnMixedList(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestParseMovePinned(t *testing.T) {
+	board, _ := Parse("4k3/4r3/8/8/8/8/4N3/4K3 w - - 0 1")
+	_, err := ParseMove(board, "e2c3")
+	if err == nil {
+		t.FailNow()
+	}
+}
+
+func TestParseMoveIntoCheck(t *testing.T) {
+	board, _ := Parse("4k3/8/8/8/8/8/3r4/4K3 w - - 0 1")
+	_, err := ParseMove(board, "e1e2")
+	if err == nil {
+		t.Fail()
+	}
+	_, err = ParseMove(board, "e1d2")
+	if err != nil {
+		t.Fail()
+	}
+}
+
+func TestPerftLegal(t *testing.T) {
+	fens := []string{START,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8"}
+	for _, fen := range fens {
+		board, _ := Parse(fen)
+		for depth := 1; depth <= 3; depth++ {
+			want := Perft(depth, board, false)
+			if got := PerftLegal(depth, board); got != want {
+				t.Error(fen, depth, got, want)
+			}
+		}
+	}
+}
+
+func TestLegalMovesEnPassantPin(t *testing.T) {
+	/* Taking en passant would leave the king open along the rank */
+	board, _ := Parse("8/8/8/KPp4r/8/8/8/4k3 w - c6 0 1")
+	for _, move := range LegalMoves(board) {
+		if move.Kind == MoveEnPassant {
+			t.Fail()
+		}
+	}
+}
+
+func BenchmarkPerftPseudoLegal(b *testing.B) {
+	board, _ := Parse("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	for i := 0; i < b.N; i++ {
+		Perft(3, board, false)
+	}
+}
+
+func BenchmarkPerftLegal(b *testing.B) {
+	board, _ := Parse("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	for i := 0; i < b.N; i++ {
+		PerftLegal(3, board)
+	}
+}
+
+/* walkevasions checks Evasions against the slow way in every position in
+ * check within depth plies. */
+func walkevasions(t *testing.T, b *Board, depth int) {
+	if InCheck(b) {
+		want := filterlegal(b, MoveGen(b))
+		got := Evasions(b)
+		if len(got) != len(want) {
+			t.Fatal(ToFEN(b), len(got), len(want))
+		}
+		for _, m := range got {
+			if !IsMoveInMoveList(t, want, m.From, m.To, m.Kind) {
+				t.Fatal(ToFEN(b), m)
+			}
+		}
+	}
+	if depth == 0 {
+		return
+	}
+	for _, move := range LegalMoves(b) {
+		undo := MakeMove(b, &move)
+		walkevasions(t, b, depth-1)
+		UnmakeMove(b, &move, undo)
+	}
+}
+
+func TestEvasions(t *testing.T) {
+	fens := []string{
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		/* Double check, and a pawn checker to take en passant */
+		"4k3/8/8/8/1b6/8/R7/r3K3 w - - 0 1",
+		"8/8/8/2k5/3Pp3/8/8/4K3 b - d3 0 1"}
+	for _, fen := range fens {
+		board, _ := Parse(fen)
+		walkevasions(t, board, 3)
+	}
+}
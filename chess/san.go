@@ -0,0 +1,186 @@
+package chess
+
+import (
+	"errors"
+	"strings"
+)
+
+/* Letters for the pieces in SAN, indexed by piece */
+const sanpieces = " PNBRQK"
+
+/*
+MoveToSAN gives move, which must be legal in b, in standard algebraic
+notation: the piece letter, as much of the from square as it takes to tell
+it apart from another piece of the same kind going to the same square, x
+for a capture, the to square, =Q and so on for a promotion, and + or # if it
+gives check or mate.
+*/
+func MoveToSAN(b *Board, move *Move) string {
+	var san strings.Builder
+	piece := GetPiece(b.Data[move.From])
+	capture := move.Kind == MoveCapture || move.Kind == MoveCapPromote ||
+		move.Kind == MoveEnPassant
+	switch {
+	case move.Kind == MoveCastle && move.To > move.From:
+		san.WriteString("O-O")
+	case move.Kind == MoveCastle:
+		san.WriteString("O-O-O")
+	case piece == PAWN:
+		if capture {
+			san.WriteString(IndexToAlgebraic(move.From)[:1])
+		}
+	default:
+		san.WriteByte(sanpieces[piece])
+		san.WriteString(disambiguate(b, move, piece))
+	}
+	if move.Kind != MoveCastle {
+		if capture {
+			san.WriteByte('x')
+		}
+		san.WriteString(IndexToAlgebraic(move.To))
+		if move.Kind == MovePromote || move.Kind == MoveCapPromote {
+			san.WriteByte('=')
+			san.WriteByte(sanpieces[move.Promote])
+		}
+	}
+	undo := MakeMove(b, move)
+	if InCheck(b) {
+		if len(LegalMoves(b)) == 0 {
+			san.WriteByte('#')
+		} else {
+			san.WriteByte('+')
+		}
+	}
+	UnmakeMove(b, move, undo)
+	return san.String()
+}
+
+/* disambiguate is the file, rank or whole square the move is from, if
+ * another piece of the same kind can go to the same square. */
+func disambiguate(b *Board, move *Move, piece byte) string {
+	from := IndexToAlgebraic(move.From)
+	ambiguous, samefile, samerank := false, false, false
+	for _, other := range LegalMoves(b) {
+		if other.To != move.To || other.From == move.From ||
+			GetPiece(b.Data[other.From]) != piece {
+			continue
+		}
+		ambiguous = true
+		square := IndexToAlgebraic(other.From)
+		samefile = samefile || square[0] == from[0]
+		samerank = samerank || square[1] == from[1]
+	}
+	switch {
+	case !ambiguous:
+		return ""
+	case !samefile:
+		return from[:1]
+	case !samerank:
+		return from[1:]
+	}
+	return from
+}
+
+/* SANLine gives a line of moves starting from b in SAN, as it would be
+ * written in a game score. b is left as it was. */
+func SANLine(b *Board, moves []Move) string {
+	line := *b
+	line.History = append([]uint64(nil), b.History...)
+	sans := make([]string, len(moves))
+	for i := range moves {
+		sans[i] = MoveToSAN(&line, &moves[i])
+		MakeMove(&line, &moves[i])
+	}
+	return strings.Join(sans, " ")
+}
+
+/*
+ParseSAN finds the legal move in b that san stands for. It is lenient about
+the usual ways people stray from the standard: castling with zeros, a
+missing or extra x, a dash between the squares, promotion without the = (or
+in lower case), check marks and annotations such as ! and ?, and the from
+square given in full - so long algebraic is understood as well.
+*/
+func ParseSAN(b *Board, san string) (*Move, error) {
+	text := strings.TrimRight(san, "+#!?")
+	var piece, promote byte
+	var fromfile, fromrank byte
+	switch strings.ToUpper(strings.Replace(text, "0", "O", -1)) {
+	case "O-O":
+		return findcastle(b, true)
+	case "O-O-O":
+		return findcastle(b, false)
+	}
+	if len(text) > 0 && strings.IndexByte(sanpieces[1:], text[0]) >= 0 {
+		piece = byte(strings.IndexByte(sanpieces, text[0]))
+		text = text[1:]
+	}
+	/* Anything after the to square is the promotion */
+	text = strings.TrimSuffix(text, ")")
+	if n := len(text); n > 0 && !(text[n-1] >= '1' && text[n-1] <= '8') {
+		p := strings.IndexByte(sanpieces[2:6], strings.ToUpper(text[n-1:])[0])
+		if p < 0 {
+			return nil, errors.New("Bad promotion: " + san)
+		}
+		promote = byte(p) + KNIGHT
+		text = strings.TrimRight(text[:n-1], "=(")
+	}
+	if len(text) < 2 {
+		return nil, errors.New("Move too short: " + san)
+	}
+	to, err := AlgebraicToIndex(text[len(text)-2:])
+	if err != nil {
+		return nil, errors.New("Bad square: " + san)
+	}
+	for _, c := range text[:len(text)-2] {
+		switch {
+		case c >= 'a' && c <= 'h':
+			fromfile = byte(c)
+		case c >= '1' && c <= '8':
+			fromrank = byte(c)
+		case c == 'x' || c == ':' || c == '-':
+		default:
+			return nil, errors.New("Bad move: " + san)
+		}
+	}
+	/* No letter means a pawn, unless the whole from square is given */
+	if piece == EMPTY && (fromfile == 0 || fromrank == 0) {
+		piece = PAWN
+	}
+	var found *Move
+	for _, move := range LegalMoves(b) {
+		from := IndexToAlgebraic(move.From)
+		if move.To != to || (piece != EMPTY && GetPiece(b.Data[move.From]) != piece) ||
+			(fromfile != 0 && from[0] != fromfile) ||
+			(fromrank != 0 && from[1] != fromrank) {
+			continue
+		}
+		if move.Kind == MovePromote || move.Kind == MoveCapPromote {
+			/* Promote to a queen if nobody says otherwise */
+			if (promote == EMPTY && move.Promote != QUEEN) ||
+				(promote != EMPTY && move.Promote != promote) {
+				continue
+			}
+		} else if promote != EMPTY {
+			continue
+		}
+		if found != nil {
+			return nil, errors.New("Ambiguous move: " + san)
+		}
+		found = new(Move)
+		*found = move
+	}
+	if found == nil {
+		return nil, errors.New("Illegal move: " + san)
+	}
+	return found, nil
+}
+
+func findcastle(b *Board, kingside bool) (*Move, error) {
+	for _, move := range LegalMoves(b) {
+		if move.Kind == MoveCastle && (move.To > move.From) == kingside {
+			return &move, nil
+		}
+	}
+	return nil, errors.New("Illegal move: can't castle")
+}
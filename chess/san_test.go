@@ -0,0 +1,80 @@
+package chess
+
+import (
+	"testing"
+)
+
+func tsan(t *testing.T, fen, long, san string) {
+	board, _ := Parse(fen)
+	move, err := ParseMove(board, long)
+	if err != nil {
+		t.Fatal(long, err)
+	}
+	if got := MoveToSAN(board, move); got != san {
+		t.Error(fen, long, got, san)
+	}
+	parsed, err := ParseSAN(board, san)
+	if err != nil || !SameMove(parsed, move) {
+		t.Error(fen, san, parsed, err)
+	}
+	if ToFEN(board) != fen {
+		t.Error("board changed", ToFEN(board))
+	}
+}
+
+func TestMoveToSAN(t *testing.T) {
+	tsan(t, START, "e2e4", "e4")
+	tsan(t, START, "g1f3", "Nf3")
+	tsan(t, kiwipete, "e1g1", "O-O")
+	tsan(t, kiwipete, "e1c1", "O-O-O")
+	tsan(t, kiwipete, "d5e6", "dxe6")
+	tsan(t, kiwipete, "f3f6", "Qxf6")
+	tsan(t, kiwipete, "e2a6", "Bxa6")
+	tsan(t, "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", "exd6")
+	tsan(t, "4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b7b8q", "b8=Q+")
+	tsan(t, "2r1k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b7c8n", "bxc8=N")
+}
+
+func TestMoveToSANDisambiguation(t *testing.T) {
+	/* Knights on b1 and f1 both reach d2: say which file */
+	tsan(t, "4k3/8/8/8/8/8/8/1N2KN2 w - - 0 1", "b1d2", "Nbd2")
+	/* Rooks on a1 and a5 share a file: say which rank */
+	tsan(t, "4k3/8/8/R7/8/8/8/R3K3 w - - 0 1", "a1a3", "R1a3")
+	/* Queens on a1, a3 and c1 all reach b2 */
+	tsan(t, "4k3/8/8/8/8/Q7/8/Q1Q1K3 w - - 0 1", "a1b2", "Qa1b2")
+}
+
+func TestMoveToSANCheckmate(t *testing.T) {
+	tsan(t, "5k2/Q7/7N/8/8/K7/8/8 w - - 0 1", "a7f7", "Qf7#")
+}
+
+func TestParseSANLenient(t *testing.T) {
+	lenient := map[string]string{
+		"0-0": "e1g1", "o-o-o": "e1c1", "de6": "d5e6", "Qf6": "f3f6",
+		"Qf3xf6": "f3f6", "e2-a6": "e2a6", "Nxd7!?": "e5d7", "g2g4": "g2g4",
+	}
+	board, _ := Parse(kiwipete)
+	for san, long := range lenient {
+		move, err := ParseSAN(board, san)
+		if err != nil || MoveToLongAlgebraic(move) != long {
+			t.Error(san, move, err)
+		}
+	}
+	board, _ = Parse("4k3/1P6/8/8/8/8/8/4K3 w - - 0 1")
+	for san, long := range map[string]string{"b8Q": "b7b8q", "b8q": "b7b8q",
+		"b8=N": "b7b8n", "b8(R)": "b7b8r", "b8": "b7b8q", "b7b8b": "b7b8b"} {
+		move, err := ParseSAN(board, san)
+		if err != nil || MoveToLongAlgebraic(move) != long {
+			t.Error(san, move, err)
+		}
+	}
+}
+
+func TestParseSANErrors(t *testing.T) {
+	board, _ := Parse("4k3/8/8/8/8/8/8/1N2KN2 w - - 0 1")
+	for _, san := range []string{"Nd2", "Nc4", "O-O", "e4", "Zd2", "N", "Nd9"} {
+		if move, err := ParseSAN(board, san); err == nil {
+			t.Error(san, move)
+		}
+	}
+}
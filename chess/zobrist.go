@@ -0,0 +1,82 @@
+package chess
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+/*
+Zobrist keys. Each (piece, square) pair, each set of castling rights, each
+en passant square and the side to move gets a random 64-bit number, and the
+key of a position is the xor of the numbers for everything in it. The
+generator is seeded with a constant so keys are the same from run to run.
+*/
+
+var (
+	zobristPiece     [16][120]uint64
+	zobristCastle    [16]uint64
+	zobristEnPassant [120]uint64
+	zobristBlack     uint64
+)
+
+/* DebugHash makes MakeMove and UnmakeMove check the incremental key against
+ * a full recompute after every move. It's slow - only for tracking down
+ * bugs. */
+var DebugHash bool
+
+func init() {
+	InitZobrist()
+}
+
+func InitZobrist() {
+	r := rand.New(rand.NewSource(0x6b7573616e616769))
+	var sq byte
+	for piece := PAWN; piece <= KING; piece++ {
+		for sq = 0; sq < 120; sq++ {
+			if OnBoard(sq) {
+				zobristPiece[WHITE|piece][sq] = r.Uint64()
+				zobristPiece[BLACK|piece][sq] = r.Uint64()
+			}
+		}
+	}
+	for i := range zobristCastle {
+		zobristCastle[i] = r.Uint64()
+	}
+	for sq = 0; sq < 120; sq++ {
+		if OnBoard(sq) {
+			zobristEnPassant[sq] = r.Uint64()
+		}
+	}
+	zobristBlack = r.Uint64()
+}
+
+func zobristsquare(b *Board, sq byte) uint64 {
+	return zobristPiece[b.Data[sq]&0x0F][sq]
+}
+
+/* HashBoard computes the key of a position from scratch. */
+func HashBoard(b *Board) uint64 {
+	var retval uint64
+	for _, sq := range b.PieceList {
+		if OnBoard(sq) {
+			retval ^= zobristsquare(b, sq)
+		}
+	}
+	retval ^= zobristCastle[b.Castle&0x0F]
+	retval ^= zobristEnPassant[b.EnPassant]
+	if b.ToMove == BLACK {
+		retval ^= zobristBlack
+	}
+	return retval
+}
+
+func CheckHash(b *Board) bool {
+	return b.Hash == HashBoard(b)
+}
+
+func debugcheckhash(b *Board, where string) {
+	if !CheckHash(b) {
+		panic(fmt.Sprintf("%s: incremental key %016x != %016x for %s",
+			where, b.Hash, HashBoard(b), ToFEN(b)))
+	}
+}
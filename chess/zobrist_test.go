@@ -0,0 +1,58 @@
+package chess
+
+import (
+	"testing"
+)
+
+func TestHashIncrementalKiwipete(t *testing.T) {
+	DebugHash = true
+	defer func() { DebugHash = false }()
+	board, _ :=
+		Parse("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq -")
+	tperftboard(t, 3, 97862, board)
+}
+
+func TestHashIncrementalPromotions(t *testing.T) {
+	DebugHash = true
+	defer func() { DebugHash = false }()
+	board, _ := Parse("n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1")
+	tperftboard(t, 3, 9483, board)
+}
+
+func TestHashTransposition(t *testing.T) {
+	a, _ := Parse(START)
+	b, _ := Parse(START)
+	for _, m := range []string{"g1f3", "g8f6", "b1c3"} {
+		move, err := ParseMove(a, m)
+		if err != nil {
+			t.FailNow()
+		}
+		MakeMove(a, move)
+	}
+	for _, m := range []string{"b1c3", "g8f6", "g1f3"} {
+		move, err := ParseMove(b, m)
+		if err != nil {
+			t.FailNow()
+		}
+		MakeMove(b, move)
+	}
+	if a.Hash != b.Hash {
+		t.Fail()
+	}
+}
+
+func TestHashSideToMove(t *testing.T) {
+	a, _ := Parse("4k3/8/8/8/8/8/8/4K3 w - - 0 1")
+	b, _ := Parse("4k3/8/8/8/8/8/8/4K3 b - - 0 1")
+	if a.Hash == b.Hash {
+		t.Fail()
+	}
+}
+
+func TestHashEnPassant(t *testing.T) {
+	a, _ := Parse("rnbqkbnr/pp1ppppp/8/2p5/4P3/8/PPPP1PPP/RNBQKBNR w KQkq c6 0 2")
+	b, _ := Parse("rnbqkbnr/pp1ppppp/8/2p5/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2")
+	if a.Hash == b.Hash {
+		t.Fail()
+	}
+}
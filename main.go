@@ -3,19 +3,22 @@ package main
 import (
 	"bufio"
 	"flag"
-	"fmt"
-	"io"
 	"log"
 	"os"
 	"runtime/pprof"
 	"strings"
+
+	"github.com/seekrit-club/kusanagi/chess"
 )
 
 var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to file")
 var verbose = flag.Bool("v", false, "verbose output")
+var debughash = flag.Bool("debughash", false, "check the zobrist key after every move (slow)")
+var pgnlog = flag.String("pgnlog", "", "append every game played through xboard to this PGN file")
 
 func main() {
 	flag.Parse()
+	chess.DebugHash = *debughash
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
 		if err != nil {
@@ -24,33 +27,41 @@ func main() {
 		pprof.StartCPUProfile(f)
 		defer pprof.StopCPUProfile()
 	}
-	board := InitState()
-	reader := bufio.NewReader(os.Stdin)
-	var result string
-	engine_side := BLACK
-	for {
-		if board.ToMove == engine_side {
-			move := FindMove(board)
-			if move == nil {
-				fmt.Println("resign")
+	commands := ReadCommands(bufio.NewReader(os.Stdin))
+	/* The first thing the GUI says tells us which protocol it speaks */
+	input, ok := <-commands
+	if !ok {
+		return
+	}
+	if input == "uci" {
+		UciLoop(commands, *verbose)
+	} else {
+		XboardLoop(commands, input, *verbose, *pgnlog)
+	}
+}
+
+/* ReadCommands reads lines in a goroutine of its own, so the engine can
+ * take commands while it searches. The channel is closed at the end of the
+ * input. */
+func ReadCommands(reader *bufio.Reader) <-chan string {
+	commands := make(chan string, 16)
+	go func() {
+		for {
+			input, err := reader.ReadString('\n')
+			if input != "" {
+				commands <- strings.TrimSpace(input)
+			}
+			if err != nil {
+				close(commands)
+				return
 			}
-			board.Moves++
-			MakeMove(board, move)
-			fmt.Println("move", MoveToLongAlgebraic(move))
-		}
-		input, err := reader.ReadString('\n')
-		if input == "quit\n" || err == io.EOF {
-			return
-		} else {
-			board, result = XboardParse(strings.TrimSpace(input),
-				board, *verbose, &engine_side)
-			fmt.Print(result)
 		}
-	}
+	}()
+	return commands
 }
 
-func InitState() *Board {
-	board := new(Board)
-	ClearBoard(board)
+func InitState() *chess.Board {
+	board := new(chess.Board)
+	chess.ClearBoard(board)
 	return board
 }
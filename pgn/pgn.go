@@ -0,0 +1,131 @@
+/* Package pgn reads and writes games in Portable Game Notation: the tag
+ * pairs, the moves in SAN, and the comments, NAGs and variations that go
+ * with them. */
+package pgn
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/seekrit-club/kusanagi/chess"
+)
+
+/* The seven tags every game has, in the order they are exported */
+var SevenTagRoster = []string{"Event", "Site", "Date", "Round", "White",
+	"Black", "Result"}
+
+/* Game termination markers */
+const (
+	WhiteWins = "1-0"
+	BlackWins = "0-1"
+	Draw      = "1/2-1/2"
+	Unknown   = "*"
+)
+
+type Tag struct {
+	Name  string
+	Value string
+}
+
+/*
+Node is one move of a game score and what was written about it. Variations
+are the alternatives to this move, each a line of moves played from the
+position before it. Before is a comment in front of the move, which only has
+anywhere to go on the first move of a variation; the comment before the main
+line belongs to the Game.
+*/
+type Node struct {
+	Move       chess.Move
+	NAGs       []int
+	Before     string
+	Comment    string
+	Variations [][]*Node
+}
+
+type Game struct {
+	Tags    []Tag
+	Comment string
+	Moves   []*Node
+	Result  string
+}
+
+func NewGame() *Game {
+	return &Game{Result: Unknown}
+}
+
+/* Tag is the value of the named tag, or "" if the game doesn't have it. */
+func (g *Game) Tag(name string) string {
+	for _, tag := range g.Tags {
+		if tag.Name == name {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+/* SetTag changes the value of a tag, adding it if need be. */
+func (g *Game) SetTag(name, value string) {
+	for i := range g.Tags {
+		if g.Tags[i].Name == name {
+			g.Tags[i].Value = value
+			return
+		}
+	}
+	g.Tags = append(g.Tags, Tag{name, value})
+}
+
+/* StartBoard is the position the game starts from: the one in the FEN tag
+ * if there is one, otherwise the usual one. */
+func (g *Game) StartBoard() (*chess.Board, error) {
+	if fen := g.Tag("FEN"); fen != "" {
+		board, err := chess.Parse(fen)
+		if err != nil {
+			return nil, err
+		}
+		return board, chess.Validate(board)
+	}
+	return chess.Parse(chess.START)
+}
+
+/* Board is the position at the end of the main line. */
+func (g *Game) Board() (*chess.Board, error) {
+	board, err := g.StartBoard()
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range g.Moves {
+		chess.MakeMove(board, &node.Move)
+	}
+	return board, nil
+}
+
+/* FromGame makes a game score of the moves played so far in rec, with its
+ * tags, result and comments. Clock readings go in the comments as
+ * [%clk h:mm:ss]. */
+func FromGame(rec *chess.Game) *Game {
+	g := NewGame()
+	g.Result = rec.Result
+	names := make([]string, 0, len(rec.Tags))
+	for name := range rec.Tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g.SetTag(name, rec.Tags[name])
+	}
+	if fen := chess.ToFEN(rec.Start); fen != chess.START {
+		g.SetTag("SetUp", "1")
+		g.SetTag("FEN", fen)
+	}
+	for _, ply := range rec.Plies[:rec.Current] {
+		node := &Node{Move: ply.Move, Comment: ply.Comment}
+		if ply.Clock > 0 {
+			seconds := int64(ply.Clock / time.Second)
+			node.Comment = join(node.Comment, fmt.Sprintf("[%%clk %d:%02d:%02d]",
+				seconds/3600, seconds/60%60, seconds%60))
+		}
+		g.Moves = append(g.Moves, node)
+	}
+	return g
+}
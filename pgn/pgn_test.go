@@ -0,0 +1,189 @@
+package pgn
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seekrit-club/kusanagi/chess"
+)
+
+const annotated = `[Event "Test"]
+[Site "?"]
+[Date "????.??.??"]
+[Round "?"]
+[White "A \"Quoted\" Name"]
+[Black "B"]
+[Result "1-0"]
+
+{Before the game} 1. e4 $1 {Best by test} 1... e5 (1... c5 2. Nf3 (2. c3 d5)
+2... d6) (1... e6) 2. Nf3 Nc6 3. Bb5 1-0
+
+`
+
+func TestReadFirstGame(t *testing.T) {
+	file, err := os.Open("../games/2017.02.22-firstgame.pgn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	games, err := ReadAll(file)
+	if err != nil || len(games) != 1 {
+		t.Fatal(len(games), err)
+	}
+	game := games[0]
+	if len(game.Moves) != 60 || game.Result != BlackWins {
+		t.Error(len(game.Moves), game.Result)
+	}
+	if game.Tag("Black") != "Dorpsgek Cosmopolitan 2" {
+		t.Error(game.Tag("Black"))
+	}
+	if !strings.HasPrefix(game.Comment, "Further analysis") {
+		t.Error(game.Comment)
+	}
+	/* Two comments after one move run together */
+	if game.Moves[1].Comment != "-0.21/13 5 Probably the best response to the van Geet/Dunst opening" {
+		t.Error(game.Moves[1].Comment)
+	}
+	board, err := game.Board()
+	if err != nil || !chess.InCheck(board) || len(chess.LegalMoves(board)) != 0 {
+		t.Error("not mate", err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	game, err := NewReader(strings.NewReader(annotated)).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Tag("White") != `A "Quoted" Name` {
+		t.Error(game.Tag("White"))
+	}
+	if nags := game.Moves[0].NAGs; len(nags) != 1 || nags[0] != 1 {
+		t.Error(nags)
+	}
+	if vars := game.Moves[1].Variations; len(vars) != 2 || len(vars[0]) != 3 ||
+		len(vars[0][1].Variations) != 1 {
+		t.Error(vars)
+	}
+	var out bytes.Buffer
+	if err := Write(&out, game); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != annotated {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), annotated)
+	}
+}
+
+func TestSuffixes(t *testing.T) {
+	game, err := NewReader(strings.NewReader("1. e4!? e5?? 2. Qh5!! *")).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, nag := range []int{5, 4, 3} {
+		if len(game.Moves[i].NAGs) != 1 || game.Moves[i].NAGs[0] != nag {
+			t.Error(i, game.Moves[i].NAGs)
+		}
+	}
+	if game.Result != Unknown || game.Tag("Result") != Unknown {
+		t.Error(game.Result)
+	}
+}
+
+func TestCastlingWithZeros(t *testing.T) {
+	game, err := NewReader(strings.NewReader(
+		"1. e4 e5 2. Nf3 Nc6 3. Bc4 Bc5 4. 0-0 Nf6 5. d3 d6 6. Bg5 h6 7. Bh4 g5 8. Bg3 Qe7 9. Nc3 Bd7 10. a3 0-0-0+!? *")).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(game.Moves) != 20 || game.Moves[6].Move.String() != "e1g1" ||
+		game.Moves[19].Move.String() != "e8c8" {
+		t.Error(len(game.Moves), game.Moves[6].Move, game.Moves[19].Move)
+	}
+	if nags := game.Moves[19].NAGs; len(nags) != 1 || nags[0] != 5 {
+		t.Error(nags)
+	}
+}
+
+func TestSetUp(t *testing.T) {
+	fen := "4k3/8/8/8/8/8/4P3/4K3 b - - 0 40"
+	game := NewGame()
+	game.SetTag("FEN", fen)
+	board, _ := game.StartBoard()
+	for _, san := range []string{"Kd7", "e4", "Kc6"} {
+		move, err := chess.ParseSAN(board, san)
+		if err != nil {
+			t.Fatal(san, err)
+		}
+		game.Moves = append(game.Moves, &Node{Move: *move})
+		chess.MakeMove(board, move)
+	}
+	var out bytes.Buffer
+	Write(&out, game)
+	if !strings.Contains(out.String(), "[SetUp \"1\"]\n[FEN \""+fen+"\"]\n\n40... Kd7 41. e4 Kc6 *\n") {
+		t.Error(out.String())
+	}
+	again, err := NewReader(&out).Next()
+	if err != nil || len(again.Moves) != 3 {
+		t.Fatal(err)
+	}
+	end, _ := again.Board()
+	if chess.ToFEN(end) != chess.ToFEN(board) {
+		t.Error(chess.ToFEN(end))
+	}
+}
+
+func TestMultipleGames(t *testing.T) {
+	text := `[Event "One"]
+
+1. e4 e5 1-0
+
+[Event "Two"]
+
+1. e4 Ke7 2. Nf3 0-1
+
+[Event "Three"]
+
+1. d4 d5 (1... Nf6) 1/2-1/2
+`
+	rd := NewReader(strings.NewReader(text))
+	if game, err := rd.Next(); err != nil || game.Tag("Event") != "One" {
+		t.Error(err)
+	}
+	/* The bad game comes back with the moves that could be read */
+	if game, err := rd.Next(); err == nil || len(game.Moves) != 1 {
+		t.Error("bad move read", err)
+	}
+	if game, err := rd.Next(); err != nil || game.Result != Draw ||
+		len(game.Moves[1].Variations) != 1 {
+		t.Error(err)
+	}
+	if _, err := rd.Next(); err != io.EOF {
+		t.Error(err)
+	}
+}
+
+func TestFromGame(t *testing.T) {
+	start, _ := chess.Parse("4k3/8/8/8/8/8/4P3/4K3 w - - 0 1")
+	rec := chess.NewGame(start)
+	rec.Tags["White"] = "Kusanagi"
+	rec.Result = Draw
+	for _, san := range []string{"e4", "Kd7"} {
+		move, _ := chess.ParseSAN(rec.Board, san)
+		rec.Play(move)
+		rec.SetClock(61 * time.Second)
+		rec.Plies[rec.Current-1].Comment = "+0.35/12"
+	}
+	rec.Undo()
+	var out bytes.Buffer
+	Write(&out, FromGame(rec))
+	want := "[White \"Kusanagi\"]\n[Black \"?\"]\n[Result \"1/2-1/2\"]\n" +
+		"[SetUp \"1\"]\n[FEN \"4k3/8/8/8/8/8/4P3/4K3 w - - 0 1\"]\n\n" +
+		"1. e4 {+0.35/12 [%clk 0:01:01]} 1/2-1/2\n\n"
+	if !strings.HasSuffix(out.String(), want) {
+		t.Error(out.String())
+	}
+}
@@ -0,0 +1,328 @@
+package pgn
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/seekrit-club/kusanagi/chess"
+)
+
+/* Kinds of token in a PGN file */
+const (
+	tokEOF byte = iota
+	tokTag
+	tokComment
+	tokOpen
+	tokClose
+	tokNAG
+	tokResult
+	tokMove
+)
+
+type token struct {
+	kind byte
+	text string
+	line int
+}
+
+/* The move suffix annotations, and the NAGs they stand for */
+var suffixes = map[string]int{"!": 1, "?": 2, "!!": 3, "??": 4, "!?": 5, "?!": 6}
+
+/* Reader reads the games of a PGN file one at a time. */
+type Reader struct {
+	r     *bufio.Reader
+	line  int
+	start bool
+	ahead *token
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r), line: 1, start: true}
+}
+
+/* ReadAll reads every game in r, stopping at the first that is bad. */
+func ReadAll(r io.Reader) ([]*Game, error) {
+	var games []*Game
+	rd := NewReader(r)
+	for {
+		game, err := rd.Next()
+		if err == io.EOF {
+			return games, nil
+		} else if err != nil {
+			return games, err
+		}
+		games = append(games, game)
+	}
+}
+
+/* frame is a line of moves being read: the main line, or a variation with
+ * the move it is an alternative to. undo takes back the last move made on
+ * board. */
+type frame struct {
+	board  *chess.Board
+	moves  []*Node
+	undo   *chess.Undo
+	parent *Node
+	before string
+}
+
+/*
+Next reads the next game, or returns io.EOF when there are none left. The
+moves are checked by playing them out from the start position. A game with a
+bad move in it is returned as far as it got, along with the error, and the
+reader carries on from the game after it.
+*/
+func (rd *Reader) Next() (*Game, error) {
+	tok := rd.token()
+	if tok.kind == tokEOF {
+		return nil, io.EOF
+	}
+	game := NewGame()
+	for ; tok.kind == tokTag; tok = rd.token() {
+		name, value, err := parsetag(tok.text)
+		if err != nil {
+			return game, rd.skip(fmt.Errorf("pgn: line %d: %s", tok.line, err))
+		}
+		game.SetTag(name, value)
+	}
+	rd.ahead = &tok
+	board, err := game.StartBoard()
+	if err != nil {
+		return game, rd.skip(fmt.Errorf("pgn: line %d: bad FEN tag: %s", tok.line, err))
+	}
+	stack := []*frame{{board: board}}
+	fail := func(err error) (*Game, error) {
+		game.Moves = stack[0].moves
+		return game, rd.skip(err)
+	}
+	for {
+		tok := rd.token()
+		top := stack[len(stack)-1]
+		var last *Node
+		if len(top.moves) > 0 {
+			last = top.moves[len(top.moves)-1]
+		}
+		switch tok.kind {
+		case tokEOF, tokTag:
+			/* The result was left off */
+			rd.ahead = &tok
+			if len(stack) > 1 {
+				game.Moves = stack[0].moves
+				return game, fmt.Errorf("pgn: line %d: unfinished variation", tok.line)
+			}
+			game.Moves = top.moves
+			if result := game.Tag("Result"); result != "" {
+				game.Result = result
+			}
+			return game, nil
+		case tokResult:
+			if len(stack) > 1 {
+				return fail(fmt.Errorf("pgn: line %d: result inside a variation", tok.line))
+			}
+			game.Moves = top.moves
+			game.Result = tok.text
+			if game.Tag("Result") == "" {
+				game.SetTag("Result", tok.text)
+			}
+			return game, nil
+		case tokComment:
+			switch {
+			case last != nil:
+				last.Comment = join(last.Comment, tok.text)
+			case len(stack) == 1:
+				game.Comment = join(game.Comment, tok.text)
+			default:
+				top.before = join(top.before, tok.text)
+			}
+		case tokNAG:
+			nag, err := strconv.Atoi(tok.text)
+			if err != nil || last == nil {
+				return fail(fmt.Errorf("pgn: line %d: bad NAG $%s", tok.line, tok.text))
+			}
+			last.NAGs = append(last.NAGs, nag)
+		case tokOpen:
+			if last == nil {
+				return fail(fmt.Errorf("pgn: line %d: variation with no move before it", tok.line))
+			}
+			/* Play the variation from the position before last */
+			board := *top.board
+			board.History = append([]uint64(nil), top.board.History...)
+			chess.UnmakeMove(&board, &last.Move, top.undo)
+			stack = append(stack, &frame{board: &board, parent: last})
+		case tokClose:
+			if len(stack) == 1 {
+				return fail(fmt.Errorf("pgn: line %d: ) with no (", tok.line))
+			}
+			top.parent.Variations = append(top.parent.Variations, top.moves)
+			stack = stack[:len(stack)-1]
+		case tokMove:
+			san, nag := splitsuffix(tok.text)
+			move, err := chess.ParseSAN(top.board, san)
+			if err != nil {
+				return fail(fmt.Errorf("pgn: line %d: %s", tok.line, err))
+			}
+			node := &Node{Move: *move, Before: top.before}
+			if nag != 0 {
+				node.NAGs = append(node.NAGs, nag)
+			}
+			top.before = ""
+			top.undo = chess.MakeMove(top.board, move)
+			top.moves = append(top.moves, node)
+		}
+	}
+}
+
+/* skip passes over the rest of a game that has gone wrong, so the next one
+ * can be read, and hands back err. */
+func (rd *Reader) skip(err error) error {
+	for {
+		tok := rd.token()
+		switch tok.kind {
+		case tokResult:
+			return err
+		case tokEOF, tokTag:
+			rd.ahead = &tok
+			return err
+		}
+	}
+}
+
+func join(comment, more string) string {
+	if comment == "" {
+		return more
+	}
+	return comment + " " + more
+}
+
+/* splitsuffix takes any !? annotation off a move, giving the NAG for it. */
+func splitsuffix(san string) (string, int) {
+	move := strings.TrimRight(san, "!?")
+	return move, suffixes[san[len(move):]]
+}
+
+/* parsetag splits the inside of a tag pair into its name and value. */
+func parsetag(text string) (string, string, error) {
+	fields := strings.SplitN(strings.TrimSpace(text), " ", 2)
+	if len(fields) != 2 {
+		return "", "", fmt.Errorf("bad tag [%s]", text)
+	}
+	quoted := strings.TrimSpace(fields[1])
+	if len(quoted) < 2 || quoted[0] != '"' || quoted[len(quoted)-1] != '"' {
+		return "", "", fmt.Errorf("bad tag value [%s]", text)
+	}
+	var value strings.Builder
+	quoted = quoted[1 : len(quoted)-1]
+	for i := 0; i < len(quoted); i++ {
+		if quoted[i] == '\\' && i+1 < len(quoted) {
+			i++
+		}
+		value.WriteByte(quoted[i])
+	}
+	return fields[0], value.String(), nil
+}
+
+/* token reads the next token, passing over move numbers and % lines. */
+func (rd *Reader) token() token {
+	if rd.ahead != nil {
+		tok := *rd.ahead
+		rd.ahead = nil
+		return tok
+	}
+	for {
+		c, err := rd.r.ReadByte()
+		if err != nil {
+			return token{kind: tokEOF, line: rd.line}
+		}
+		start := rd.start
+		rd.start = c == '\n'
+		line := rd.line
+		switch {
+		case c == '\n':
+			rd.line++
+		case c == ' ' || c == '\t' || c == '\r' || c == '.':
+		case c == '%' && start:
+			rd.until('\n')
+			rd.start = true
+		case c == '[':
+			return token{tokTag, rd.until(']'), line}
+		case c == '{':
+			text := rd.until('}')
+			return token{tokComment, strings.Join(strings.Fields(text), " "), line}
+		case c == ';':
+			text := rd.until('\n')
+			rd.start = true
+			return token{tokComment, strings.TrimSpace(text), line}
+		case c == '(':
+			return token{tokOpen, "(", line}
+		case c == ')':
+			return token{tokClose, ")", line}
+		case c == '*':
+			return token{tokResult, Unknown, line}
+		case c == '$':
+			return token{tokNAG, rd.symbol(), line}
+		default:
+			rd.r.UnreadByte()
+			symbol := rd.symbol()
+			if symbol == "" {
+				/* Not something that belongs in PGN at all */
+				rd.r.ReadByte()
+				continue
+			}
+			switch symbol {
+			case WhiteWins, BlackWins, Draw:
+				return token{tokResult, symbol, line}
+			}
+			/* Castling with zeros, which would go with the move number */
+			switch strings.TrimRight(symbol, "+#!?") {
+			case "0-0", "0-0-0":
+				return token{tokMove, symbol, line}
+			}
+			/* A move number, perhaps with the move stuck on */
+			symbol = strings.TrimLeft(strings.TrimLeft(symbol, "0123456789"), ".")
+			if symbol == "" {
+				continue
+			}
+			return token{tokMove, symbol, line}
+		}
+	}
+}
+
+/* symbol reads the characters that make up a move or a number. */
+func (rd *Reader) symbol() string {
+	var text strings.Builder
+	for {
+		c, err := rd.r.ReadByte()
+		if err != nil {
+			break
+		}
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			strings.IndexByte("+#=:-/!?_.", c) >= 0) {
+			rd.r.UnreadByte()
+			break
+		}
+		text.WriteByte(c)
+	}
+	return text.String()
+}
+
+/* until reads up to and past end, and returns what came before it. */
+func (rd *Reader) until(end byte) string {
+	var text strings.Builder
+	for {
+		c, err := rd.r.ReadByte()
+		if err != nil || c == end {
+			break
+		}
+		if c == '\n' {
+			rd.line++
+		}
+		text.WriteByte(c)
+	}
+	if end == '\n' {
+		rd.line++
+	}
+	return text.String()
+}
@@ -0,0 +1,139 @@
+package pgn
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/seekrit-club/kusanagi/chess"
+)
+
+/* Movetext lines are kept shorter than this */
+const linewidth = 80
+
+/* Values the seven tags are given when a game doesn't have them */
+var rosterdefaults = map[string]string{"Date": "????.??.??"}
+
+/*
+Write writes g to w in export format: the seven tag roster in order, then the
+game's other tags, a blank line, and the movetext in SAN wrapped to under 80
+columns, ending with the result and a blank line to separate it from the game
+after.
+*/
+func Write(w io.Writer, g *Game) error {
+	board, err := g.StartBoard()
+	if err != nil {
+		return err
+	}
+	var text strings.Builder
+	for _, name := range SevenTagRoster {
+		value := g.Tag(name)
+		switch {
+		case name == "Result":
+			value = g.Result
+		case value == "" && rosterdefaults[name] != "":
+			value = rosterdefaults[name]
+		case value == "":
+			value = "?"
+		}
+		writetag(&text, name, value)
+	}
+	if g.Tag("FEN") != "" && g.Tag("SetUp") == "" {
+		writetag(&text, "SetUp", "1")
+	}
+	for _, tag := range g.Tags {
+		if !roster(tag.Name) {
+			writetag(&text, tag.Name, tag.Value)
+		}
+	}
+	text.WriteByte('\n')
+	var tokens []string
+	if g.Comment != "" {
+		tokens = comment(tokens, g.Comment)
+	}
+	tokens = movetext(tokens, board, g.Moves)
+	tokens = append(tokens, g.Result)
+	length := 0
+	for _, token := range tokens {
+		if length > 0 && length+1+len(token) >= linewidth {
+			text.WriteByte('\n')
+			length = 0
+		} else if length > 0 {
+			text.WriteByte(' ')
+			length++
+		}
+		text.WriteString(token)
+		length += len(token)
+	}
+	text.WriteString("\n\n")
+	_, err = io.WriteString(w, text.String())
+	return err
+}
+
+func roster(name string) bool {
+	for _, tag := range SevenTagRoster {
+		if tag == name {
+			return true
+		}
+	}
+	return false
+}
+
+func writetag(text *strings.Builder, name, value string) {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `"`, `\"`, -1)
+	fmt.Fprintf(text, "[%s \"%s\"]\n", name, value)
+}
+
+/* comment adds a comment to tokens a word at a time, so it can be wrapped
+ * like the rest of the movetext. */
+func comment(tokens []string, text string) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return tokens
+	}
+	words[0] = "{" + words[0]
+	words[len(words)-1] += "}"
+	return append(tokens, words...)
+}
+
+/* movetext adds the tokens for a line of moves played from board, with the
+ * variations on them. board is left at the end of the line. Black's moves
+ * get a number only where the reader might have lost track of it. */
+func movetext(tokens []string, board *chess.Board, line []*Node) []string {
+	number := true
+	for _, node := range line {
+		if node.Before != "" {
+			tokens = comment(tokens, node.Before)
+			number = true
+		}
+		if board.ToMove == chess.WHITE {
+			tokens = append(tokens, fmt.Sprintf("%d.", board.FullMoves))
+		} else if number {
+			tokens = append(tokens, fmt.Sprintf("%d...", board.FullMoves))
+		}
+		tokens = append(tokens, chess.MoveToSAN(board, &node.Move))
+		number = false
+		for _, nag := range node.NAGs {
+			tokens = append(tokens, fmt.Sprintf("$%d", nag))
+		}
+		if node.Comment != "" {
+			tokens = comment(tokens, node.Comment)
+			number = true
+		}
+		for _, variation := range node.Variations {
+			if len(variation) == 0 {
+				continue
+			}
+			before := *board
+			before.History = append([]uint64(nil), board.History...)
+			start := len(tokens)
+			tokens = movetext(tokens, &before, variation)
+			tokens[start] = "(" + tokens[start]
+			tokens[len(tokens)-1] += ")"
+			number = true
+		}
+		chess.MakeMove(board, &node.Move)
+	}
+	return tokens
+}
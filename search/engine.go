@@ -0,0 +1,94 @@
+package search
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/seekrit-club/kusanagi/chess"
+)
+
+/*
+Engine is everything one player needs to search: its clock and time control,
+its hash table, and the counters of the search in progress. Engines share
+nothing, so one process can run as many games or analyses at once as it
+likes, but a single Engine only searches one position at a time.
+
+The clock fields are as the GUI last told us, and the time control is the
+one from level (or the UCI go command). TimeRepeat is the number of moves per
+period, or 0 for the whole game.
+*/
+type Engine struct {
+	Clock      time.Duration
+	OppClock   time.Duration
+	TimeRepeat int
+	TimePerTC  time.Duration
+	TimeInc    time.Duration
+
+	TT *TransTable
+
+	/* State of the running search. Only the goroutine doing the search
+	 * touches these; everyone else asks it to stop through its context. */
+	nodecount uint64
+	nodelimit uint64
+	seldepth  int
+	abort     bool
+	ctx       context.Context
+	deadline  time.Time
+	start     time.Time
+	output    InfoFunc
+
+	status     SearchStatus
+	statuslock sync.Mutex
+}
+
+func NewEngine() *Engine {
+	return &Engine{TT: NewTransTable(DEFAULTHASHMB), ctx: context.Background()}
+}
+
+/* ResizeHash replaces the hash table with an empty one of mb megabytes. */
+func (e *Engine) ResizeHash(mb int) {
+	e.TT = NewTransTable(mb)
+}
+
+/* SearchStatus is a snapshot of how far a running search has got, for
+ * progress reports such as xboard's stat01. */
+type SearchStatus struct {
+	Start     time.Time
+	Depth     int
+	Nodes     uint64
+	RootMoves int
+	RootDone  int
+	RootMove  chess.Move
+}
+
+/* Status can be called from any goroutine. */
+func (e *Engine) Status() SearchStatus {
+	e.statuslock.Lock()
+	defer e.statuslock.Unlock()
+	return e.status
+}
+
+func (e *Engine) setstatus(update func(*SearchStatus)) {
+	e.statuslock.Lock()
+	update(&e.status)
+	e.statuslock.Unlock()
+}
+
+/* countnode counts a node and says whether the search has been stopped.
+ * Every POLLNODES nodes it checks the context and the hard deadline, so the
+ * cost of asking is kept off the rest. */
+func (e *Engine) countnode() bool {
+	e.nodecount++
+	if e.nodecount%POLLNODES == 0 && !e.abort {
+		if e.ctx.Err() != nil ||
+			(!e.deadline.IsZero() && time.Now().After(e.deadline)) {
+			e.abort = true
+		}
+		e.setstatus(func(s *SearchStatus) { s.Nodes = e.nodecount })
+	}
+	if e.nodelimit > 0 && e.nodecount >= e.nodelimit {
+		e.abort = true
+	}
+	return e.abort
+}
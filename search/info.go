@@ -0,0 +1,73 @@
+package search
+
+import (
+	"time"
+
+	"github.com/seekrit-club/kusanagi/chess"
+)
+
+/* What an Info is reporting */
+const (
+	InfoIteration byte = iota // an iteration has finished, with its PV
+	InfoRootMove              // the search has gone on to another root move
+	InfoResult                // the search is over; returned by Search
+)
+
+/* How to read an Info's score */
+const (
+	ScoreCentipawns byte = iota
+	ScoreMate            // Mate says in how many moves
+)
+
+/*
+Info is a report on the progress of a search. Depth is the iteration the
+report belongs to and SelDepth the furthest ply it has reached, quiescence
+included. RootMove and RootMoveNumber, counting from 1, are only filled in
+for InfoRootMove; PV is empty for it.
+*/
+type Info struct {
+	Kind      byte
+	Depth     int
+	SelDepth  int
+	Score     int
+	ScoreType byte
+	/* Moves to mate, negative when it is us being mated */
+	Mate           int
+	Nodes          uint64
+	NPS            uint64
+	Time           time.Duration
+	PV             []chess.Move
+	RootMove       chess.Move
+	RootMoveNumber int
+}
+
+/* InfoFunc is given each report as the search makes it, on the search's
+ * own goroutine, so it should not take long. */
+type InfoFunc func(info Info)
+
+/* MateIn turns a mate score into moves to mate, negative when being mated,
+ * or 0 if the score is not a mate. */
+func MateIn(score int) int {
+	if score > MATEBOUND {
+		return (MATE - score + 1) / 2
+	} else if score < -MATEBOUND {
+		return -(MATE + score) / 2
+	}
+	return 0
+}
+
+/* info makes a report with the score and counters filled in. */
+func (e *Engine) info(kind byte, depth, score int, start time.Time,
+	pv []chess.Move) Info {
+	elapsed := time.Since(start)
+	info := Info{Kind: kind, Depth: depth, SelDepth: e.seldepth, Score: score,
+		Nodes: e.nodecount, Time: elapsed, PV: pv}
+	if score > MATEBOUND || score < -MATEBOUND {
+		info.ScoreType = ScoreMate
+		info.Mate = MateIn(score)
+	}
+	if elapsed > 0 {
+		info.NPS = e.nodecount * uint64(time.Second) / uint64(elapsed)
+	}
+	return info
+}
@@ -0,0 +1,413 @@
+/* Package search finds moves in a chess.Board: evaluation, alpha-beta with
+ * a transposition table, and the time management that decides when to stop
+ * iterative deepening. */
+package search
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/seekrit-club/kusanagi/chess"
+)
+
+const INFINITY int = int(math.MaxInt32) // I win!
+const MATE int = INFINITY - 10          // Value of a checkmate in 1
+
+const MAXDEPTH int = 64
+
+/* Quiescence gives up and takes the evaluation this far from the root, in
+ * case checks and evasions go back and forth for ever. */
+const MAXPLY int = 2 * MAXDEPTH
+
+/* How often, in nodes, the search looks to see whether it should stop */
+const POLLNODES uint64 = 1024
+
+func Evaluate(board *chess.Board) int {
+	phase := calcphase(board)
+	opening := MaterialCount(board, false)
+	endgame := MaterialCount(board, true)
+	score := ((opening * (256 - phase)) + (endgame * phase)) / 256
+	if board.ToMove == chess.BLACK {
+		score = -score
+	}
+	return score
+}
+
+func calcphase(board *chess.Board) int {
+	TotalPhase := 24 // PawnPhase*16 + KnightPhase*4 + BishopPhase*4 + RookPhase*4 + QueenPhase*2
+	phase := TotalPhase
+	for _, i := range board.PieceList {
+		phase -= PValue[chess.GetPiece(board.Data[i])]
+	}
+	return (phase*256 + (TotalPhase / 2)) / TotalPhase
+}
+
+func MaterialCount(b *chess.Board, endgame bool) int {
+	var retval int
+	for _, i := range b.PieceList {
+		if chess.OnBoard(i) && chess.GetPiece(b.Data[i]) != chess.EMPTY {
+			if chess.GetSide(b.Data[i]) == chess.WHITE {
+				retval += Value[chess.GetPiece(b.Data[i])] + Pst(chess.GetPiece(b.Data[i]), chess.WHITE, i, endgame)
+			} else {
+				retval -= (Value[chess.GetPiece(b.Data[i])] + Pst(chess.GetPiece(b.Data[i]), chess.BLACK, i, endgame))
+			}
+		}
+	}
+	return retval
+}
+
+func Pst(piece, side, index byte, endgame bool) int {
+	switch piece {
+	case chess.PAWN:
+		return GetPst(index, side, pstPawnMg[:], pstPawnEg[:], endgame)
+	case chess.KNIGHT:
+		return GetPst(index, side, pstKnightMg[:], pstKnightEg[:], endgame)
+	case chess.BISHOP:
+		return GetPst(index, side, pstBishopMg[:], pstBishopEg[:], endgame)
+	case chess.ROOK:
+		return GetPst(index, side, pstRookMg[:], pstRookEg[:], endgame)
+	case chess.QUEEN:
+		return GetPst(index, side, pstQueenMg[:], pstQueenEg[:], endgame)
+	case chess.KING:
+		return GetPst(index, side, pstKingMg[:], pstKingEg[:], endgame)
+	default:
+		return 0
+	}
+}
+
+func GetPst(index, side byte, tableMg []int, tableEg []int, endgame bool) int {
+	var table []int
+	if endgame {
+		table = tableEg[:]
+	} else {
+		table = tableMg[:]
+	}
+	file, rank := chess.IndexToCartesian(index)
+	var tableindex byte
+	if side == chess.BLACK {
+		tableindex = (8 * (7 - rank)) + file
+	} else {
+		tableindex = (8 * rank) + file
+	}
+	return table[tableindex]
+}
+
+func MVVLVA(board *chess.Board, move chess.Move) int {
+	from_piece := chess.GetPiece(board.Data[move.From])
+	to_piece := chess.GetPiece(board.Data[move.To])
+	if move.Kind == chess.MoveEnPassant {
+		to_piece = chess.PAWN
+	}
+
+	return Value[to_piece] - int(from_piece)
+}
+
+func iscapture(move *chess.Move) bool {
+	return move.Kind == chess.MoveCapture || move.Kind == chess.MoveCapPromote ||
+		move.Kind == chess.MoveEnPassant
+}
+
+/* SortMoves scores moves for ordering; the hash move, if it's in the list,
+ * goes first. Captures are ordered by SEE, with MVVLVA to split ties, so
+ * the winning ones come before the quiet moves and the losing ones after:
+ * a capture scores below zero exactly when SEE says it loses material. */
+func SortMoves(board *chess.Board, moves []chess.Move, hashmove *chess.Move) {
+	for i, m := range moves {
+		if chess.SameMove(&m, hashmove) {
+			moves[i].Score = INFINITY
+		} else if iscapture(&m) {
+			moves[i].Score = SEE(board, m)*1024 + MVVLVA(board, m)
+		} else {
+			moves[i].Score = MVVLVA(board, m)
+		}
+	}
+}
+
+func (e *Engine) Quies(board *chess.Board, alpha, beta, mate int) int {
+	if e.countnode() {
+		return 0
+	}
+	ply := MATE - mate
+	if ply > e.seldepth {
+		e.seldepth = ply
+	}
+	var hashmove, best chess.Move
+	if entry, ok := e.TT.Probe(board.Hash); ok {
+		hashmove = entry.Move
+		if score, cut := TTCutoff(entry, 0, alpha, beta, ply); cut {
+			return score
+		}
+	}
+	if ply >= MAXPLY {
+		return Evaluate(board)
+	}
+	/* In check there is no standing pat: every evasion is searched, and
+	 * having none is mate. */
+	incheck := chess.InCheck(board)
+	oldalpha := alpha
+	var moves []chess.Move
+	if incheck {
+		moves = chess.Evasions(board)
+		if len(moves) == 0 {
+			return -mate
+		}
+	} else {
+		eval := Evaluate(board)
+		if eval >= beta {
+			e.TT.Store(board.Hash, hashmove, ScoreToTT(beta, ply), 0, BoundLower)
+			return beta
+		}
+		if eval > alpha {
+			alpha = eval
+		}
+		moves = chess.FilterCaptures(chess.MoveGen(board))
+	}
+
+	SortMoves(board, moves, &hashmove)
+
+	sort.Slice(moves, func(i, j int) bool { return moves[i].Score > moves[j].Score })
+
+	for _, move := range moves {
+
+		/* Losing captures are sorted last and not worth a look */
+		if !incheck && move.Score < 0 {
+			break
+		}
+
+		undo := chess.MakeMove(board, &move)
+		if !incheck && chess.Illegal(board) {
+			chess.UnmakeMove(board, &move, undo)
+			continue
+		}
+		val := -e.Quies(board, -beta, -alpha, mate-1)
+		chess.UnmakeMove(board, &move, undo)
+		if e.abort {
+			return 0
+		}
+		if val >= beta {
+			e.TT.Store(board.Hash, move, ScoreToTT(beta, ply), 0, BoundLower)
+			return beta
+		}
+		if val > alpha {
+			alpha = val
+			best = move
+		}
+	}
+	if alpha > oldalpha {
+		e.TT.Store(board.Hash, best, ScoreToTT(alpha, ply), 0, BoundExact)
+	} else {
+		e.TT.Store(board.Hash, best, ScoreToTT(alpha, ply), 0, BoundUpper)
+	}
+	return alpha
+}
+
+func (e *Engine) AlphaBeta(board *chess.Board, depth, alpha, beta, mate int, pline *[]chess.Move) int {
+	if e.countnode() {
+		return 0
+	}
+
+	legal := 0
+
+	ply := MATE - mate
+	if ply > e.seldepth {
+		e.seldepth = ply
+	}
+
+	/* Any repetition inside the search is as good as a draw: whatever
+	 * got us here can be played again. */
+	if ply > 0 && (chess.IsRepetition(board) || chess.IsFiftyMoves(board)) {
+		*pline = nil
+		return 0
+	}
+
+	if depth <= 0 {
+		*pline = nil
+		return e.Quies(board, alpha, beta, mate)
+	}
+
+	var hashmove, best chess.Move
+
+	if entry, ok := e.TT.Probe(board.Hash); ok {
+		hashmove = entry.Move
+		/* Never cut at the root: we need a move to play */
+		if ply > 0 {
+			if score, cut := TTCutoff(entry, depth, alpha, beta, ply); cut {
+				*pline = nil
+				return score
+			}
+		}
+	}
+
+	oldalpha := alpha
+
+	var line []chess.Move
+
+	/* At the root, weed out the illegal moves before the loop, so that
+	 * progress reports only ever mention moves we could play. In check,
+	 * the evasions are all there is to look at. */
+	incheck := chess.InCheck(board)
+	var moves []chess.Move
+	if ply == 0 {
+		moves = chess.LegalMoves(board)
+	} else if incheck {
+		moves = chess.Evasions(board)
+	} else {
+		moves = chess.MoveGen(board)
+	}
+
+	SortMoves(board, moves, &hashmove)
+
+	sort.Slice(moves, func(i, j int) bool { return moves[i].Score > moves[j].Score })
+
+	for _, move := range moves {
+
+		undo := chess.MakeMove(board, &move)
+
+		if ply > 0 && !incheck && chess.Illegal(board) {
+			chess.UnmakeMove(board, &move, undo)
+			continue
+		}
+
+		line = nil
+
+		if ply == 0 {
+			e.setstatus(func(s *SearchStatus) {
+				s.RootMove = move
+				s.RootDone = legal
+			})
+			if e.output != nil {
+				info := e.info(InfoRootMove, depth, 0, e.start, nil)
+				info.RootMove = move
+				info.RootMoveNumber = legal + 1
+				e.output(info)
+			}
+		}
+
+		val := -e.AlphaBeta(board, depth-1, -beta, -alpha, mate-1, &line)
+
+		chess.UnmakeMove(board, &move, undo)
+
+		if e.abort {
+			return 0
+		}
+
+		if val >= beta {
+			e.TT.Store(board.Hash, move, ScoreToTT(beta, ply), depth, BoundLower)
+			return beta
+		}
+
+		if val > alpha {
+			alpha = val
+			best = move
+			*pline = append([]chess.Move{move}, line...)
+		}
+
+		legal++
+	}
+
+	if legal == 0 {
+
+		if !incheck {
+			return 0
+		}
+
+		return -mate
+	}
+
+	if alpha > oldalpha {
+		e.TT.Store(board.Hash, best, ScoreToTT(alpha, ply), depth, BoundExact)
+	} else {
+		e.TT.Store(board.Hash, best, ScoreToTT(alpha, ply), depth, BoundUpper)
+	}
+
+	return alpha
+}
+
+/* SearchLimits are the extra conditions a search can be given beyond the
+ * clock. Zero values mean no limit. */
+type SearchLimits struct {
+	Depth     int
+	Nodes     uint64
+	MoveTime  time.Duration
+	MovesToGo int
+	/* Search until cancelled, ignoring the clock */
+	Infinite bool
+}
+
+func (e *Engine) FindMove(ctx context.Context, board *chess.Board) *chess.Move {
+	result := e.Search(ctx, board, SearchLimits{}, nil)
+	if len(result.PV) == 0 {
+		return nil
+	}
+	return &result.PV[0]
+}
+
+/* Search runs iterative deepening until the limits or the clock say stop,
+ * or ctx is cancelled. Each finished iteration and each new root move is
+ * reported to output, which may be nil. The result has the principal
+ * variation of the last completed iteration, which is empty only when there
+ * are no legal moves. */
+func (e *Engine) Search(ctx context.Context, board *chess.Board, limits SearchLimits,
+	output InfoFunc) Info {
+	tm := e.NewTimeManager(board, limits)
+	start := tm.Start
+	e.nodecount = 0
+	e.nodelimit = limits.Nodes
+	e.seldepth = 0
+	e.abort = false
+	e.ctx = ctx
+	e.deadline = time.Time{}
+	e.start = start
+	e.output = output
+	rootmoves := chess.CountLegal(board)
+	e.setstatus(func(s *SearchStatus) {
+		*s = SearchStatus{Start: start, RootMoves: rootmoves}
+	})
+	var last Info
+	for depth := 1; depth <= MAXDEPTH; depth++ {
+		e.setstatus(func(s *SearchStatus) { s.Depth = depth })
+		iterstart := time.Now()
+		var line []chess.Move
+		score := e.AlphaBeta(board, depth, -INFINITY, INFINITY, MATE, &line)
+		if e.abort {
+			/* Root moves searched before the abort are still good
+			 * if we have nothing better. */
+			if last.PV == nil {
+				last.PV = line
+			}
+			if last.PV == nil && rootmoves > 0 {
+				/* Stopped before any move was searched */
+				last.PV = []chess.Move{firstlegal(board)}
+			}
+			break
+		}
+		last = e.info(InfoIteration, depth, score, start, line)
+		if output != nil {
+			output(last)
+		}
+		if len(line) == 0 || depth == limits.Depth {
+			break
+		}
+		if limits.Infinite {
+			continue
+		}
+		if !tm.Continue(depth, score, line[0], time.Since(iterstart)) {
+			break
+		}
+		/* The first iteration always finishes, so we have a move */
+		if depth == 1 {
+			e.deadline = tm.Start.Add(tm.Hard)
+		}
+	}
+	e.output = nil
+	return e.info(InfoResult, last.Depth, last.Score, start, last.PV)
+}
+
+func firstlegal(board *chess.Board) chess.Move {
+	if moves := chess.LegalMoves(board); len(moves) > 0 {
+		return moves[0]
+	}
+	return chess.Move{}
+}
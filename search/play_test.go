@@ -0,0 +1,164 @@
+package search
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seekrit-club/kusanagi/chess"
+)
+
+func playmoves(t *testing.T, board *chess.Board, moves ...string) {
+	for _, m := range moves {
+		move, err := chess.ParseMove(board, m)
+		if err != nil {
+			t.Fatal(m, err)
+		}
+		chess.MakeMove(board, move)
+	}
+}
+
+func TestMateInOne(t *testing.T) {
+	board, _ := chess.Parse("5k2/Q7/7N/8/8/K/8/8 w - - 0 1")
+	to, _ := chess.AlgebraicToIndex("f7")
+	from, _ := chess.AlgebraicToIndex("a7")
+	e := NewEngine()
+	e.Clock, _ = time.ParseDuration("5m")
+	e.TimeInc, _ = time.ParseDuration("8s")
+	move := e.FindMove(context.Background(), board)
+
+	if move.To != to || move.From != from {
+		t.Log(move)
+		t.FailNow()
+	}
+}
+
+func TestSortMovesHashMoveFirst(t *testing.T) {
+	board, _ := chess.Parse(chess.START)
+	moves := chess.MoveGen(board)
+	hashmove := moves[len(moves)-1]
+	SortMoves(board, moves, &hashmove)
+	for _, m := range moves[:len(moves)-1] {
+		if m.Score >= moves[len(moves)-1].Score {
+			t.Fail()
+		}
+	}
+}
+
+func TestAlphaBetaRepetitionIsDraw(t *testing.T) {
+	/* White is a rook down, but can repeat the position */
+	board, _ := chess.Parse("4k3/8/8/8/8/8/r7/4K1N1 w - - 10 40")
+	if chess.InCheck(board) {
+		t.Fatal("white starts in check")
+	}
+	playmoves(t, board, "g1f3", "a2a3", "f3g1", "a3a2", "g1f3", "a2a3", "f3g1")
+	var line []chess.Move
+	score := NewEngine().AlphaBeta(board, 2, -INFINITY, INFINITY, MATE-1, &line)
+	if score != 0 {
+		t.Log(score)
+		t.Fail()
+	}
+}
+
+func TestSearchCancel(t *testing.T) {
+	board, _ := chess.Parse(chess.START)
+	ctx, cancel := context.WithTimeout(context.Background(),
+		100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	pv := NewEngine().Search(ctx, board, SearchLimits{Infinite: true}, nil).PV
+	if time.Since(start) > time.Second || len(pv) == 0 {
+		t.Fail()
+	}
+	if chess.ToFEN(board) != chess.START {
+		t.Fail()
+	}
+}
+
+func TestSearchCancelledBeforeStart(t *testing.T) {
+	board, _ := chess.Parse(chess.START)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pv := NewEngine().Search(ctx, board, SearchLimits{Infinite: true}, nil).PV
+	if len(pv) == 0 {
+		t.Fail()
+	}
+}
+
+func TestEnginesIndependent(t *testing.T) {
+	/* Two games at once must find what each finds on its own */
+	fens := []string{chess.START, "5k2/Q7/7N/8/8/K/8/8 w - - 0 1"}
+	limits := SearchLimits{Depth: 4}
+	alone := make([][]chess.Move, len(fens))
+	for i, fen := range fens {
+		board, _ := chess.Parse(fen)
+		alone[i] = NewEngine().Search(context.Background(), board, limits, nil).PV
+	}
+	together := make([][]chess.Move, len(fens))
+	var wg sync.WaitGroup
+	for i, fen := range fens {
+		wg.Add(1)
+		go func(i int, fen string) {
+			defer wg.Done()
+			board, _ := chess.Parse(fen)
+			together[i] = NewEngine().Search(context.Background(), board,
+				limits, nil).PV
+		}(i, fen)
+	}
+	wg.Wait()
+	for i := range fens {
+		if len(alone[i]) == 0 || len(together[i]) != len(alone[i]) {
+			t.Fatal(alone[i], together[i])
+		}
+		for j := range alone[i] {
+			if !chess.SameMove(&alone[i][j], &together[i][j]) {
+				t.Fatal(alone[i], together[i])
+			}
+		}
+	}
+}
+
+func TestSearchInfo(t *testing.T) {
+	board, _ := chess.Parse("5k2/Q7/7N/8/8/K/8/8 w - - 0 1")
+	var iterations, rootmoves []Info
+	result := NewEngine().Search(context.Background(), board,
+		SearchLimits{Depth: 3, Infinite: true}, func(info Info) {
+			switch info.Kind {
+			case InfoIteration:
+				iterations = append(iterations, info)
+			case InfoRootMove:
+				rootmoves = append(rootmoves, info)
+			}
+		})
+	if len(iterations) != 3 || result.Kind != InfoResult || result.Depth != 3 {
+		t.Fatal(len(iterations), result)
+	}
+	for i, info := range iterations {
+		if info.Depth != i+1 || len(info.PV) == 0 || info.SelDepth < info.Depth {
+			t.Error(info)
+		}
+	}
+	if result.ScoreType != ScoreMate || result.Mate != 1 ||
+		result.Nodes < iterations[2].Nodes {
+		t.Error(result)
+	}
+	legal := chess.CountLegal(board)
+	if len(rootmoves) != 3*legal || rootmoves[legal-1].RootMoveNumber != legal {
+		t.Error(len(rootmoves), legal)
+	}
+}
+
+func TestQuiesInCheck(t *testing.T) {
+	/* Fool's mate: quiescence has to see there is no way out */
+	board, _ := chess.Parse("rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3")
+	if score := NewEngine().Quies(board, -INFINITY, INFINITY, MATE); score != -MATE {
+		t.Error(score)
+	}
+	/* A queen up, but in check from a knight forking king and queen:
+	 * standing pat would say we are winning. */
+	board, _ = chess.Parse("4k3/8/8/8/8/3n4/1Q6/4K3 w - - 0 1")
+	if score := NewEngine().Quies(board, -INFINITY, INFINITY, MATE); score > 500 {
+		t.Error(score)
+	}
+}
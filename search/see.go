@@ -0,0 +1,75 @@
+package search
+
+import (
+	"github.com/seekrit-club/kusanagi/chess"
+)
+
+/*
+SEE is the static exchange evaluation of a capture: the material the side
+making it can expect to come out with if both sides keep recapturing on the
+square, least valuable piece first, and each may stop when it likes.
+
+Pieces are taken off a copy of the board as they capture, so a slider lined
+up behind another (an x-ray) joins in once the one in front has gone.
+*/
+func SEE(board *chess.Board, move chess.Move) int {
+	exchange := *board
+	data := &exchange.Data
+	to := move.To
+	var gain [32]int
+	captured := chess.GetPiece(data[to])
+	if move.Kind == chess.MoveEnPassant {
+		captured = chess.PAWN
+		if board.ToMove == chess.BLACK {
+			data[to+10] = chess.EMPTY
+		} else {
+			data[to-10] = chess.EMPTY
+		}
+	}
+	gain[0] = Value[captured]
+	/* The piece now standing on the square, which may be taken next */
+	piece := chess.GetPiece(data[move.From])
+	if move.Kind == chess.MovePromote || move.Kind == chess.MoveCapPromote {
+		gain[0] += Value[move.Promote] - Value[chess.PAWN]
+		piece = move.Promote
+	}
+	data[move.From] = chess.EMPTY
+	side := board.ToMove ^ chess.BLACK
+	depth := 0
+	for depth < len(gain)-1 {
+		from, attacker := leastattacker(&exchange, to, side)
+		if from == chess.INVALID {
+			break
+		}
+		/* The king may only take if nothing can take it back */
+		if attacker == chess.KING {
+			if other, _ := leastattacker(&exchange, to, side^chess.BLACK); other != chess.INVALID {
+				break
+			}
+		}
+		depth++
+		gain[depth] = Value[piece] - gain[depth-1]
+		data[from] = chess.EMPTY
+		piece = attacker
+		side ^= chess.BLACK
+	}
+	/* Each side takes back only when it pays */
+	for ; depth > 0; depth-- {
+		if -gain[depth-1] < gain[depth] {
+			gain[depth-1] = -gain[depth]
+		}
+	}
+	return gain[0]
+}
+
+/* leastattacker finds side's least valuable piece attacking to, and where
+ * it is, or INVALID if there are none. */
+func leastattacker(board *chess.Board, to, side byte) (byte, byte) {
+	var best, bestpiece byte = chess.INVALID, chess.KING + 1
+	for _, from := range chess.Attackers(board, to, side) {
+		if piece := chess.GetPiece(board.Data[from]); piece < bestpiece {
+			best, bestpiece = from, piece
+		}
+	}
+	return best, bestpiece
+}
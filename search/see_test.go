@@ -0,0 +1,44 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/seekrit-club/kusanagi/chess"
+)
+
+func tsee(t *testing.T, fen, move string, expected int) {
+	board, _ := chess.Parse(fen)
+	m, err := chess.ParseMove(board, move)
+	if err != nil {
+		t.Fatal(move, err)
+	}
+	if see := SEE(board, *m); see != expected {
+		t.Error(fen, move, see, expected)
+	}
+}
+
+func TestSEEUndefended(t *testing.T) {
+	tsee(t, "4k3/8/8/4n3/8/8/8/4RK2 w - - 0 1", "e1e5", 300)
+}
+
+func TestSEEDefended(t *testing.T) {
+	tsee(t, "4k3/8/3p4/4n3/3P4/8/8/4K3 w - - 0 1", "d4e5", 200)
+	tsee(t, "4k3/8/3p4/4p3/8/8/8/4RK2 w - - 0 1", "e1e5", -350)
+}
+
+func TestSEEXRay(t *testing.T) {
+	/* The rook behind wins the exchange on e5 back */
+	tsee(t, "4r1k1/8/8/4p3/8/8/4R3/4R1K1 w - - 0 1", "e2e5", 100)
+	/* The queen behind the bishop makes taking back too dear */
+	tsee(t, "4r1k1/8/8/4p3/3B4/2Q5/8/6K1 w - - 0 1", "d4e5", 100)
+}
+
+func TestSEEKing(t *testing.T) {
+	/* The king can only take back when the square is not covered */
+	tsee(t, "4R3/8/8/8/8/5k2/4p3/K7 w - - 0 1", "e8e2", -350)
+	tsee(t, "4R3/8/8/8/8/5k2/4p3/K1N5 w - - 0 1", "e8e2", 100)
+}
+
+func TestSEEEnPassant(t *testing.T) {
+	tsee(t, "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", 100)
+}
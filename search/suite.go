@@ -0,0 +1,87 @@
+package search
+
+import (
+	"context"
+	"errors"
+
+	"github.com/seekrit-club/kusanagi/chess"
+)
+
+/* SuiteResult is how the engine did on one position of a test suite. */
+type SuiteResult struct {
+	Position *chess.EPD
+	/* What Search returned */
+	Info   Info
+	Solved bool
+	/* Why the position couldn't be checked, if it couldn't */
+	Err error
+}
+
+/*
+Solves says whether the search result answers the position: its move must be
+one of the bm moves, if there are any, and none of the am moves, and if
+there is a dm it must have found a mate at least that quick.
+*/
+func Solves(position *chess.EPD, result Info) (bool, error) {
+	bm, err := position.Moves("bm")
+	if err != nil {
+		return false, err
+	}
+	am, err := position.Moves("am")
+	if err != nil {
+		return false, err
+	}
+	dm, dmerr := position.Int("dm")
+	if len(bm) == 0 && len(am) == 0 && dmerr != nil {
+		return false, errors.New("No bm, am or dm to check against")
+	}
+	if len(result.PV) == 0 {
+		return false, nil
+	}
+	move := &result.PV[0]
+	if dmerr == nil && (result.ScoreType != ScoreMate || result.Mate <= 0 ||
+		int64(result.Mate) > dm) {
+		return false, nil
+	}
+	for i := range am {
+		if chess.SameMove(move, &am[i]) {
+			return false, nil
+		}
+	}
+	for i := range bm {
+		if chess.SameMove(move, &bm[i]) {
+			return true, nil
+		}
+	}
+	return len(bm) == 0, nil
+}
+
+/*
+RunSuite searches each position of a test suite with limits, which should
+have a depth or a move time, starting each with an empty hash table. Each
+result is handed to report as soon as it is known. It returns how many were
+solved, stopping early if ctx is cancelled.
+*/
+func (e *Engine) RunSuite(ctx context.Context, suite []*chess.EPD, limits SearchLimits,
+	report func(SuiteResult)) int {
+	if limits.MoveTime == 0 {
+		limits.Infinite = true
+	}
+	solved := 0
+	for _, position := range suite {
+		if ctx.Err() != nil {
+			break
+		}
+		e.TT.Clear()
+		result := SuiteResult{Position: position}
+		result.Info = e.Search(ctx, position.Board, limits, nil)
+		result.Solved, result.Err = Solves(position, result.Info)
+		if result.Solved {
+			solved++
+		}
+		if report != nil {
+			report(result)
+		}
+	}
+	return solved
+}
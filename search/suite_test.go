@@ -0,0 +1,69 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"github.com/seekrit-club/kusanagi/chess"
+)
+
+const backrank = "6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - "
+
+func TestSolves(t *testing.T) {
+	tests := []struct {
+		ops    string
+		move   string
+		mate   int
+		solved bool
+	}{
+		{"bm Ra8#;", "a1a8", 1, true},
+		{"bm Ra8#;", "a1a7", 0, false},
+		{"am Ra8;", "a1a8", 1, false},
+		{"am Ra8;", "g1f1", 0, true},
+		{"dm 1;", "a1a8", 1, true},
+		{"dm 1;", "a1a8", 0, false},
+		{"bm Ra8; dm 2;", "a1a8", 1, true},
+	}
+	for _, test := range tests {
+		epd, err := chess.ParseEPD(backrank + test.ops)
+		if err != nil {
+			t.Fatal(err)
+		}
+		move, _ := chess.ParseMove(epd.Board, test.move)
+		result := Info{PV: []chess.Move{*move}}
+		if test.mate > 0 {
+			result.ScoreType, result.Mate = ScoreMate, test.mate
+		}
+		if solved, err := Solves(epd, result); solved != test.solved || err != nil {
+			t.Error(test.ops, test.move, solved, err)
+		}
+	}
+	epd, _ := chess.ParseEPD(backrank + "id \"nothing to check\";")
+	if _, err := Solves(epd, Info{}); err == nil {
+		t.Error("no error with nothing to check")
+	}
+}
+
+func TestRunSuite(t *testing.T) {
+	var suite []*chess.EPD
+	for _, line := range []string{backrank + "bm Ra8#;", backrank + "am Rb1;",
+		backrank + "bm Kf1;"} {
+		epd, err := chess.ParseEPD(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		suite = append(suite, epd)
+	}
+	var reports []SuiteResult
+	e := NewEngine()
+	solved := e.RunSuite(context.Background(), suite, SearchLimits{Depth: 2},
+		func(result SuiteResult) {
+			reports = append(reports, result)
+		})
+	if solved != 2 || len(reports) != 3 {
+		t.Fatal(solved, len(reports))
+	}
+	if !reports[0].Solved || !reports[1].Solved || reports[2].Solved {
+		t.Error(reports)
+	}
+}
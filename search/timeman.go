@@ -0,0 +1,112 @@
+package search
+
+import (
+	"time"
+
+	"github.com/seekrit-club/kusanagi/chess"
+)
+
+/* Time held back on every move for the GUI and the pipe */
+const MOVEOVERHEAD time.Duration = 50 * time.Millisecond
+
+/* How many more moves we plan for when the time has to last all game */
+const SUDDENDEATHMOVES int = 30
+
+/* Each iteration is expected to take this many times as long as the last */
+const BRANCHING = 2
+
+/*
+TimeManager decides when a search should stop. The soft limit is what we aim
+to spend on the move; the hard limit is what we must never go over, and the
+search is stopped when it is reached. Between iterations, Continue says
+whether the next one is worth starting.
+*/
+type TimeManager struct {
+	Start time.Time
+	Soft  time.Duration
+	Hard  time.Duration
+	/* The previous iteration, for spotting a change of mind */
+	prevbest  chess.Move
+	prevscore int
+	prevdepth int
+}
+
+/* MovesToGo is how many moves the clock has to last, counting this one. */
+func (e *Engine) MovesToGo(board *chess.Board, limits SearchLimits) int {
+	if limits.MovesToGo > 0 {
+		return limits.MovesToGo
+	}
+	if e.TimeRepeat > 0 {
+		played := board.FullMoves - 1
+		return e.TimeRepeat - played%e.TimeRepeat
+	}
+	return SUDDENDEATHMOVES
+}
+
+/* AllotTime is the soft limit: an even share of the clock over the moves
+ * to go, plus most of the increment. We take a little more when ahead of
+ * the opponent on the clock, and a little less when behind. */
+func (e *Engine) AllotTime(board *chess.Board, limits SearchLimits) time.Duration {
+	soft, _ := e.allot(board, limits)
+	return soft
+}
+
+func (e *Engine) allot(board *chess.Board, limits SearchLimits) (time.Duration, time.Duration) {
+	if limits.MoveTime > 0 {
+		return limits.MoveTime, limits.MoveTime
+	}
+	clock := e.Clock - MOVEOVERHEAD
+	if clock < time.Millisecond {
+		clock = time.Millisecond
+	}
+	soft := clock/time.Duration(e.MovesToGo(board, limits)) + e.TimeInc*3/4
+	if e.OppClock > 0 {
+		if e.Clock > e.OppClock+e.OppClock/4 {
+			soft += soft / 8
+		} else if e.Clock < e.OppClock-e.OppClock/4 {
+			soft -= soft / 8
+		}
+	}
+	hard := clock * 3 / 4
+	if soft*4 < hard {
+		hard = soft * 4
+	}
+	if soft > hard {
+		soft = hard
+	}
+	return soft, hard
+}
+
+func (e *Engine) NewTimeManager(board *chess.Board, limits SearchLimits) *TimeManager {
+	soft, hard := e.allot(board, limits)
+	return &TimeManager{Start: time.Now(), Soft: soft, Hard: hard}
+}
+
+/* Continue is told about each completed iteration, and says whether there
+ * is time to start another. A change of best move or a falling score buys
+ * extra time, up to the hard limit; an iteration that would not finish in
+ * time is not started at all. */
+func (tm *TimeManager) Continue(depth, score int, best chess.Move,
+	itertime time.Duration) bool {
+	target := tm.Soft
+	if tm.prevdepth > 0 {
+		if !chess.SameMove(&best, &tm.prevbest) {
+			target += target / 2
+		}
+		if score < tm.prevscore-30 {
+			target += target / 2
+		}
+	}
+	if target > tm.Hard {
+		target = tm.Hard
+	}
+	tm.prevbest = best
+	tm.prevscore = score
+	tm.prevdepth = depth
+	/* A mate this short can't be improved on by looking deeper */
+	if (score > MATEBOUND && MATE-score <= depth) ||
+		(score < -MATEBOUND && MATE+score <= depth) {
+		return false
+	}
+	return time.Since(tm.Start)+itertime*BRANCHING <= target
+}
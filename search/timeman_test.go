@@ -0,0 +1,98 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seekrit-club/kusanagi/chess"
+)
+
+func clockengine(clock time.Duration, repeat int, inc time.Duration) *Engine {
+	e := NewEngine()
+	e.Clock = clock
+	e.TimeRepeat = repeat
+	e.TimeInc = inc
+	return e
+}
+
+func TestMovesToGoRepeating(t *testing.T) {
+	e := clockengine(5*time.Minute, 40, 0)
+	board, _ := chess.Parse("4k3/8/8/8/8/8/8/4K3 w - - 0 1")
+	if e.MovesToGo(board, SearchLimits{}) != 40 {
+		t.Fail()
+	}
+	board, _ = chess.Parse("4k3/8/8/8/8/8/8/4K3 b - - 0 45")
+	if e.MovesToGo(board, SearchLimits{}) != 36 {
+		t.Fail()
+	}
+	if e.MovesToGo(board, SearchLimits{MovesToGo: 3}) != 3 {
+		t.Fail()
+	}
+}
+
+func TestMovesToGoSuddenDeath(t *testing.T) {
+	e := clockengine(5*time.Minute, 0, 0)
+	board, _ := chess.Parse(chess.START)
+	if e.MovesToGo(board, SearchLimits{}) != SUDDENDEATHMOVES {
+		t.Fail()
+	}
+}
+
+func TestAllotTimeIncrement(t *testing.T) {
+	board, _ := chess.Parse(chess.START)
+	e := clockengine(time.Minute, 0, 0)
+	without := e.AllotTime(board, SearchLimits{})
+	e.TimeInc = 2 * time.Second
+	with := e.AllotTime(board, SearchLimits{})
+	if with-without != 1500*time.Millisecond {
+		t.Log(without, with)
+		t.Fail()
+	}
+}
+
+func TestAllotTimeMoveTime(t *testing.T) {
+	board, _ := chess.Parse(chess.START)
+	e := clockengine(time.Minute, 0, 0)
+	tm := e.NewTimeManager(board, SearchLimits{MoveTime: time.Second})
+	if tm.Soft != time.Second || tm.Hard != time.Second {
+		t.Fail()
+	}
+}
+
+func TestAllotTimeLastMoveOfControl(t *testing.T) {
+	/* With one move to go we may use most, but never all, of the clock */
+	board, _ := chess.Parse("4k3/8/8/8/8/8/8/4K3 w - - 0 40")
+	e := clockengine(10*time.Second, 40, 0)
+	tm := e.NewTimeManager(board, SearchLimits{})
+	if tm.Hard >= e.Clock || tm.Soft > tm.Hard || tm.Soft < e.Clock/2 {
+		t.Log(tm.Soft, tm.Hard)
+		t.Fail()
+	}
+}
+
+func TestTimeManagerInstability(t *testing.T) {
+	tm := &TimeManager{Start: time.Now(), Soft: 100 * time.Millisecond,
+		Hard: time.Second}
+	a := chess.Move{From: chess.A1, To: chess.A1 + 10, Kind: chess.MoveQuiet}
+	b := chess.Move{From: chess.A1, To: chess.A1 + 20, Kind: chess.MoveQuiet}
+	if !tm.Continue(1, 0, a, 30*time.Millisecond) {
+		t.Fail()
+	}
+	/* A stable iteration that wouldn't finish in time stops... */
+	tm.Start = time.Now().Add(-90 * time.Millisecond)
+	if tm.Continue(2, 0, a, 10*time.Millisecond) {
+		t.Fail()
+	}
+	/* ...but a change of mind earns more time */
+	if !tm.Continue(3, 0, b, 10*time.Millisecond) {
+		t.Fail()
+	}
+}
+
+func TestTimeManagerStopsOnShortMate(t *testing.T) {
+	tm := &TimeManager{Start: time.Now(), Soft: time.Hour, Hard: time.Hour}
+	a := chess.Move{From: chess.A1, To: chess.A1 + 10, Kind: chess.MoveQuiet}
+	if tm.Continue(3, MATE-3, a, 0) {
+		t.Fail()
+	}
+}
@@ -0,0 +1,142 @@
+package search
+
+import (
+	"unsafe"
+
+	"github.com/seekrit-club/kusanagi/chess"
+)
+
+/* Kinds of bound a transposition table score can be */
+const (
+	BoundNone byte = iota
+	BoundExact
+	BoundLower // score >= the stored score (failed high)
+	BoundUpper // score <= the stored score (failed low)
+)
+
+/* Scores this close to MATE are mates, and are stored relative to the node
+ * rather than the root so they stay correct when found at another ply. */
+const MATEBOUND int = MATE - 1000
+
+const TTBUCKET int = 4
+
+const DEFAULTHASHMB int = 16
+
+type TTEntry struct {
+	Key   uint64
+	Move  chess.Move
+	Score int
+	Depth int
+	Bound byte
+}
+
+type ttbucket [TTBUCKET]TTEntry
+
+type TransTable struct {
+	buckets []ttbucket
+	mask    uint64
+}
+
+/* NewTransTable makes a table using at most mb megabytes. The number of
+ * buckets is rounded down to a power of two so a key can be masked into an
+ * index. */
+func NewTransTable(mb int) *TransTable {
+	if mb < 1 {
+		mb = 1
+	}
+	count := uint64(mb) * 1024 * 1024 / uint64(unsafe.Sizeof(ttbucket{}))
+	var size uint64 = 1
+	for size*2 <= count {
+		size *= 2
+	}
+	return &TransTable{make([]ttbucket, size), size - 1}
+}
+
+func (tt *TransTable) Clear() {
+	for i := range tt.buckets {
+		tt.buckets[i] = ttbucket{}
+	}
+}
+
+func ScoreToTT(score, ply int) int {
+	if score > MATEBOUND {
+		return score + ply
+	} else if score < -MATEBOUND {
+		return score - ply
+	}
+	return score
+}
+
+func ScoreFromTT(score, ply int) int {
+	if score > MATEBOUND {
+		return score - ply
+	} else if score < -MATEBOUND {
+		return score + ply
+	}
+	return score
+}
+
+func (tt *TransTable) Probe(key uint64) (*TTEntry, bool) {
+	bucket := &tt.buckets[key&tt.mask]
+	for i := range bucket {
+		if bucket[i].Bound != BoundNone && bucket[i].Key == key {
+			return &bucket[i], true
+		}
+	}
+	return nil, false
+}
+
+/* Store replaces the entry for the same position if there is one, and
+ * otherwise the shallowest entry in the bucket. */
+func (tt *TransTable) Store(key uint64, move chess.Move, score, depth int, bound byte) {
+	bucket := &tt.buckets[key&tt.mask]
+	replace := &bucket[0]
+	for i := range bucket {
+		if bucket[i].Key == key || bucket[i].Bound == BoundNone {
+			replace = &bucket[i]
+			break
+		}
+		if bucket[i].Depth < replace.Depth {
+			replace = &bucket[i]
+		}
+	}
+	if replace.Key == key && replace.Depth > depth && bound != BoundExact {
+		/* Keep the deeper result, but remember the newer move */
+		if move.From != chess.INVALID {
+			replace.Move = move
+		}
+		return
+	}
+	if move.From == chess.INVALID && replace.Key == key {
+		move = replace.Move
+	}
+	*replace = TTEntry{key, move, score, depth, bound}
+}
+
+/* TTCutoff says whether an entry is good enough to return from a node
+ * searched to depth with the window (alpha, beta), and the score to use. */
+func TTCutoff(entry *TTEntry, depth, alpha, beta, ply int) (int, bool) {
+	if entry.Depth < depth {
+		return 0, false
+	}
+	score := ScoreFromTT(entry.Score, ply)
+	switch entry.Bound {
+	case BoundExact:
+		/* The search fails hard, so keep the score inside the window */
+		if score >= beta {
+			return beta, true
+		} else if score <= alpha {
+			return alpha, true
+		}
+		return score, true
+	case BoundLower:
+		if score >= beta {
+			return beta, true
+		}
+	case BoundUpper:
+		if score <= alpha {
+			return alpha, true
+		}
+	}
+	return 0, false
+}
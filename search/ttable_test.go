@@ -0,0 +1,64 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/seekrit-club/kusanagi/chess"
+)
+
+func TestTransTableStoreProbe(t *testing.T) {
+	tt := NewTransTable(1)
+	move := chess.Move{From: chess.A1, To: chess.A1 + 10, Kind: chess.MoveQuiet}
+	tt.Store(0xdeadbeef, move, 42, 3, BoundExact)
+	entry, ok := tt.Probe(0xdeadbeef)
+	if !ok || entry.Score != 42 || entry.Depth != 3 ||
+		entry.Bound != BoundExact || !chess.SameMove(&entry.Move, &move) {
+		t.FailNow()
+	}
+	if _, ok = tt.Probe(0xdeadbeee); ok {
+		t.Fail()
+	}
+	tt.Clear()
+	if _, ok = tt.Probe(0xdeadbeef); ok {
+		t.Fail()
+	}
+}
+
+func TestTransTableKeepsDeeperEntry(t *testing.T) {
+	tt := NewTransTable(1)
+	move := chess.Move{From: chess.A1, To: chess.A1 + 10, Kind: chess.MoveQuiet}
+	tt.Store(1234, move, 10, 6, BoundLower)
+	tt.Store(1234, chess.Move{}, 20, 2, BoundUpper)
+	entry, ok := tt.Probe(1234)
+	if !ok || entry.Depth != 6 || entry.Score != 10 ||
+		!chess.SameMove(&entry.Move, &move) {
+		t.Fail()
+	}
+}
+
+func TestTransTableBucketReplacesShallowest(t *testing.T) {
+	tt := NewTransTable(1)
+	stride := tt.mask + 1
+	for i := 0; i < TTBUCKET; i++ {
+		tt.Store(uint64(i)*stride, chess.Move{}, 0, 10-i, BoundExact)
+	}
+	tt.Store(uint64(TTBUCKET)*stride, chess.Move{}, 0, 5, BoundExact)
+	if _, ok := tt.Probe(uint64(TTBUCKET-1) * stride); ok {
+		t.Fail()
+	}
+	if _, ok := tt.Probe(0); !ok {
+		t.Fail()
+	}
+}
+
+func TestScoreTTMateAdjust(t *testing.T) {
+	/* Mated three plies below a node at ply 4 */
+	score := -(MATE - 7)
+	stored := ScoreToTT(score, 4)
+	if stored != -(MATE-3) || ScoreFromTT(stored, 4) != score {
+		t.Fail()
+	}
+	if ScoreToTT(150, 4) != 150 || ScoreFromTT(150, 9) != 150 {
+		t.Fail()
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package search
 
 /* Lyudmil's PST values, in centipawns */
 
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seekrit-club/kusanagi/chess"
+	"github.com/seekrit-club/kusanagi/search"
+)
+
+const UCIID string = "id name Kusanagi\nid author japanoise\n"
+
+const UCIOPTIONS string = "option name Hash type spin default 16 min 1 max 4096\n" +
+	"option name Clear Hash type button\n" +
+	"option name Ponder type check default false\n"
+
+/* UciState is what the UCI front end needs to remember between commands. A
+ * search runs in its own goroutine so that stop and ponderhit can be read
+ * while it thinks. */
+type UciState struct {
+	board   *chess.Board
+	engine  *search.Engine
+	verbose bool
+	/* Stops the search in progress */
+	cancel context.CancelFunc
+	/* Closed by the search goroutine once bestmove has been sent */
+	done chan struct{}
+	/* An infinite or ponder search holds its bestmove until this closes */
+	release     chan struct{}
+	releaseonce *sync.Once
+	/* Time allotted to a ponder search should it become a real one */
+	ponderallot time.Duration
+	timer       *time.Timer
+}
+
+func UciLoop(commands <-chan string, verbose bool) {
+	board, _ := chess.Parse(chess.START)
+	state := &UciState{board: board, engine: search.NewEngine(),
+		verbose: verbose}
+	fmt.Print(UciParse("uci", state))
+	for line := range commands {
+		if line == "quit" {
+			break
+		}
+		fmt.Print(UciParse(line, state))
+	}
+	state.stop()
+}
+
+func UciParse(line string, state *UciState) string {
+	if state.verbose {
+		log.Println(line)
+	}
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return ""
+	}
+	switch words[0] {
+	case "uci":
+		return UCIID + UCIOPTIONS + "uciok\n"
+	case "isready":
+		return "readyok\n"
+	case "setoption":
+		state.stop()
+		return ucisetoption(words, state)
+	case "ucinewgame":
+		state.stop()
+		state.engine.TT.Clear()
+	case "position":
+		state.stop()
+		board, err := uciposition(words)
+		if err != nil {
+			return fmt.Sprintln("info string", err)
+		}
+		state.board = board
+	case "go":
+		state.stop()
+		return ucigo(words, state)
+	case "stop":
+		state.stop()
+	case "ponderhit":
+		state.ponderhit()
+	case "d":
+		return chess.PrintBoard(state.board)
+	}
+	return ""
+}
+
+func ucisetoption(words []string, state *UciState) string {
+	var name, value []string
+	var field *[]string
+	for _, word := range words[1:] {
+		switch word {
+		case "name":
+			field = &name
+		case "value":
+			field = &value
+		default:
+			if field != nil {
+				*field = append(*field, word)
+			}
+		}
+	}
+	switch strings.ToLower(strings.Join(name, " ")) {
+	case "hash":
+		mb, err := strconv.Atoi(strings.Join(value, " "))
+		if err != nil {
+			return fmt.Sprintln("info string", err)
+		}
+		state.engine.ResizeHash(mb)
+	case "clear hash":
+		state.engine.TT.Clear()
+	case "ponder":
+		/* Nothing to do: the GUI tells us when to ponder */
+	}
+	return ""
+}
+
+func uciposition(words []string) (*chess.Board, error) {
+	if len(words) < 2 {
+		return nil, errors.New("position: missing position")
+	}
+	moves := len(words)
+	for i, word := range words {
+		if word == "moves" {
+			moves = i
+			break
+		}
+	}
+	var board *chess.Board
+	var err error
+	switch words[1] {
+	case "startpos":
+		board, err = chess.Parse(chess.START)
+	case "fen":
+		board, err = chess.Parse(strings.Join(words[2:moves], " "))
+		if err == nil {
+			err = chess.Validate(board)
+		}
+	default:
+		err = errors.New("position: expected startpos or fen")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := moves + 1; i < len(words); i++ {
+		move, err := chess.ParseMove(board, words[i])
+		if err != nil {
+			return nil, fmt.Errorf("position: %s: %s", words[i], err)
+		}
+		chess.MakeMove(board, move)
+	}
+	return board, nil
+}
+
+func ucigo(words []string, state *UciState) string {
+	var limits search.SearchLimits
+	var wtime, btime, winc, binc time.Duration
+	timed, ponder, infinite := false, false, false
+	for i := 1; i < len(words); i++ {
+		var arg int
+		var err error
+		switch words[i] {
+		case "infinite":
+			infinite = true
+			continue
+		case "ponder":
+			ponder = true
+			continue
+		case "wtime", "btime", "winc", "binc", "movetime", "movestogo",
+			"depth", "nodes":
+			if i+1 < len(words) {
+				arg, err = strconv.Atoi(words[i+1])
+			} else {
+				err = errors.New("missing value")
+			}
+			if err != nil {
+				return fmt.Sprintln("info string go:", words[i], err)
+			}
+		default:
+			continue
+		}
+		ms := time.Duration(arg) * time.Millisecond
+		switch words[i] {
+		case "wtime":
+			wtime, timed = ms, true
+		case "btime":
+			btime, timed = ms, true
+		case "winc":
+			winc = ms
+		case "binc":
+			binc = ms
+		case "movetime":
+			limits.MoveTime = ms
+		case "movestogo":
+			limits.MovesToGo = arg
+		case "depth":
+			limits.Depth = arg
+		case "nodes":
+			limits.Nodes = uint64(arg)
+		}
+		i++
+	}
+	engine := state.engine
+	if state.board.ToMove == chess.WHITE {
+		engine.Clock, engine.OppClock, engine.TimeInc = wtime, btime, winc
+	} else {
+		engine.Clock, engine.OppClock, engine.TimeInc = btime, wtime, binc
+	}
+	/* The GUI counts the moves to go for us, or it's sudden death */
+	engine.TimeRepeat = 0
+	/* With no clock to go by, only depth, nodes or stop end the search;
+	 * only go infinite and go ponder hold back the bestmove */
+	limits.Infinite = infinite || (!timed && limits.MoveTime == 0)
+	state.release = make(chan struct{})
+	state.releaseonce = new(sync.Once)
+	state.done = make(chan struct{})
+	if ponder {
+		if !limits.Infinite {
+			state.ponderallot = engine.AllotTime(state.board, limits)
+		} else {
+			state.ponderallot = 0
+		}
+		limits.Infinite = true
+	} else if !infinite {
+		state.releasebestmove()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	state.cancel = cancel
+	go ucisearch(ctx, engine, state.board, limits, state.release, state.done)
+	return ""
+}
+
+func ucisearch(ctx context.Context, engine *search.Engine, board *chess.Board,
+	limits search.SearchLimits, release, done chan struct{}) {
+	pv := engine.Search(ctx, board, limits, UciInfo).PV
+	<-release
+	switch len(pv) {
+	case 0:
+		fmt.Println("bestmove 0000")
+	case 1:
+		fmt.Println("bestmove", chess.MoveToLongAlgebraic(&pv[0]))
+	default:
+		fmt.Println("bestmove", chess.MoveToLongAlgebraic(&pv[0]), "ponder",
+			chess.MoveToLongAlgebraic(&pv[1]))
+	}
+	close(done)
+}
+
+func (state *UciState) releasebestmove() {
+	state.releaseonce.Do(func() { close(state.release) })
+}
+
+/* stop ends the running search, if any, and waits for its bestmove. */
+func (state *UciState) stop() {
+	if state.done == nil {
+		return
+	}
+	state.cancel()
+	state.releasebestmove()
+	<-state.done
+	state.done = nil
+	if state.timer != nil {
+		state.timer.Stop()
+		state.timer = nil
+	}
+}
+
+/* ponderhit turns the ponder search into a real one: it may now send its
+ * bestmove, and gets the time it would have been given. */
+func (state *UciState) ponderhit() {
+	if state.done == nil {
+		return
+	}
+	if state.ponderallot > 0 {
+		state.timer = time.AfterFunc(state.ponderallot, state.cancel)
+	}
+	state.releasebestmove()
+}
+
+func UciScore(info search.Info) string {
+	if info.ScoreType == search.ScoreMate {
+		return fmt.Sprintf("mate %d", info.Mate)
+	}
+	return fmt.Sprintf("cp %d", info.Score)
+}
+
+/* UciInfo reports a search's progress as info lines. The move being
+ * searched is only worth mentioning once the search has taken a while. */
+func UciInfo(info search.Info) {
+	ms := int64(info.Time / time.Millisecond)
+	switch info.Kind {
+	case search.InfoIteration:
+		line := make([]string, len(info.PV))
+		for i := range info.PV {
+			line[i] = chess.MoveToLongAlgebraic(&info.PV[i])
+		}
+		fmt.Printf("info depth %d seldepth %d score %s nodes %d nps %d time %d pv %s\n",
+			info.Depth, info.SelDepth, UciScore(info), info.Nodes,
+			info.NPS, ms, strings.Join(line, " "))
+	case search.InfoRootMove:
+		if info.Time >= time.Second {
+			fmt.Printf("info depth %d currmove %s currmovenumber %d\n",
+				info.Depth, chess.MoveToLongAlgebraic(&info.RootMove),
+				info.RootMoveNumber)
+		}
+	}
+}
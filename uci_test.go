@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seekrit-club/kusanagi/chess"
+	"github.com/seekrit-club/kusanagi/search"
+)
+
+/* captureoutput runs f with standard output going to a pipe, and gives back
+ * what was written to it. */
+func captureoutput(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	f()
+	os.Stdout = stdout
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	return string(out)
+}
+
+func TestUciGoDepthSendsBestmove(t *testing.T) {
+	board, _ := chess.Parse(chess.START)
+	state := &UciState{board: board, engine: search.NewEngine()}
+	out := captureoutput(t, func() {
+		UciParse("go depth 2", state)
+		/* No stop: the bestmove has to come once depth 2 is done */
+		select {
+		case <-state.done:
+		case <-time.After(10 * time.Second):
+			t.Error("no bestmove without stop")
+			state.stop()
+		}
+	})
+	if !strings.Contains(out, "info depth 2 ") || !strings.Contains(out, "bestmove ") {
+		t.Error(out)
+	}
+}
@@ -1,39 +1,721 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/seekrit-club/kusanagi/chess"
+	"github.com/seekrit-club/kusanagi/pgn"
+	"github.com/seekrit-club/kusanagi/search"
+)
+
+const XBOARDFEATURES string = "feature done=0 usermove=1 setboard=1 myname=\"Kusanagi\" sigterm=0 sigint=0 debug=1 ping=1 colors=0 memory=1 playother=1 name=1 nps=1 smp=1 analyze=1 draw=0 done=1\n" // our response to the protover command
+
+/* Commands that change or look at the position or the hash table, which
+ * the search has while it runs, so it has to be stopped first */
+var xboardstopcommands = map[string]bool{"new": true, "setboard": true,
+	"usermove": true, "undo": true, "remove": true, "go": true,
+	"playother": true, "white": true, "black": true, "force": true,
+	"result": true, "analyze": true, "exit": true, "edit": true,
+	"level": true, "memory": true, "d": true, "fen": true, "perft": true, "divide": true,
+	"testsuite": true, "perftsuite": true}
+
+/* What a background search is for */
+const (
+	SearchThink byte = iota
+	SearchPonder
+	SearchAnalyze
 )
 
-const XBOARDFEATURES string = "feature done=0 usermove=1 setboard=1 myname=\"Kusanagi\" sigterm=0 sigint=0 debug=1 ping=1 colors=0 done=1\n" // our response to the protover command
+/* xboardsearch is a search running in its own goroutine. cancel stops it;
+ * done is closed when it returns, leaving its result behind. */
+type xboardsearch struct {
+	kind   byte
+	cancel context.CancelFunc
+	done   chan struct{}
+	result search.Info
+	/* A copy of the position searched, without its history, for the
+	 * front end to look at: the board itself belongs to the search */
+	position chess.Board
+	finished bool
+	timer    *time.Timer
+}
+
+/* XboardState is everything the xboard front end keeps between commands. */
+type XboardState struct {
+	Board      *chess.Board
+	Engine     *search.Engine
+	EngineSide byte
+	Verbose    bool
+	/* The game since the last new or setboard, whose Board is Board */
+	Game *chess.Game
+	/* Limits from sd and st */
+	Limits search.SearchLimits
+	/* Nodes per second to use in place of the clock, from nps */
+	NPS int
+	/* Whether to think on the opponent's time, from hard and easy */
+	Ponder    bool
+	Cores     int
+	Opponent  string
+	Computer  bool
+	Rating    int
+	OppRate   int
+	Analyzing bool
+	/* File to append each finished game to, if any, and whether this
+	 * one has been */
+	PGNLog string
+	logged bool
+	/* The search in progress, if any */
+	job *xboardsearch
+	/* Commands that have to wait until the engine has moved */
+	queue []string
+	/* While pondering, pondermove has been made on the board and the
+	 * search is of the reply to it. */
+	pondermove chess.Move
+	/* In edit mode, commands place pieces on EditBoard */
+	Editing   bool
+	EditColor byte
+	EditBoard *chess.Board
+}
+
+func NewXboardState(verbose bool) *XboardState {
+	state := &XboardState{Engine: search.NewEngine(),
+		EngineSide: chess.BLACK, Verbose: verbose, Cores: 1}
+	xboardsetboard(state, InitState())
+	return state
+}
+
+/* XboardLoop plays through the xboard protocol, starting with input and
+ * then taking commands as they arrive, even while the engine thinks. */
+func XboardLoop(commands <-chan string, input string, verbose bool, pgnlog string) {
+	state := NewXboardState(verbose)
+	state.PGNLog = pgnlog
+	for {
+		if !XboardCommand(state, input) {
+			return
+		}
+		input = ""
+		for input == "" {
+			xboardstartsearch(state)
+			select {
+			case line, ok := <-commands:
+				if !ok {
+					xboardstopsearch(state)
+					return
+				}
+				input = line
+			case <-xboardsearchdone(state):
+				xboardfinished(state)
+			}
+		}
+	}
+}
+
+/*
+XboardCommand deals with one command, and says whether to carry on. While a
+search runs:
+
+  - clock updates, status requests, settings that don't change the
+    position, draw offers and commands we don't know are handled straight
+    away and the search carries on;
+  - ? makes the engine move now with what it has found;
+  - ping waits until after the move if the engine is thinking about one,
+    otherwise it is answered straight away;
+  - the move we were pondering on turns the ponder search into a real one;
+  - anything else stops the search, throwing its result away, and is then
+    handled as usual.
+*/
+func XboardCommand(state *XboardState, input string) bool {
+	if input == "quit" {
+		xboardstopsearch(state)
+		return false
+	}
+	if job := state.job; job != nil {
+		words := strings.Fields(input)
+		if len(words) == 0 {
+			return true
+		}
+		switch words[0] {
+		case "easy":
+			/* No more pondering, but thinking goes on */
+			if job.kind == SearchPonder {
+				xboardstopsearch(state)
+			}
+		case "ping":
+			if job.kind == SearchThink {
+				state.queue = append(state.queue, input)
+			} else {
+				fmt.Print(XboardParse(input, state))
+			}
+			return true
+		case "?":
+			if job.kind == SearchThink {
+				job.cancel()
+			}
+			return true
+		case "usermove":
+			if job.kind == SearchPonder && len(words) > 1 &&
+				words[1] == chess.MoveToLongAlgebraic(&state.pondermove) {
+				xboardponderhit(state)
+				return true
+			}
+		}
+		if xboardstopcommands[words[0]] {
+			xboardstopsearch(state)
+		}
+	}
+	fmt.Print(XboardParse(input, state))
+	return true
+}
+
+/* xboardstartsearch starts whatever search there should be running in the
+ * background when none is: analysis in analyze mode, or thinking about a
+ * move when it is the engine's turn. */
+func xboardstartsearch(state *XboardState) {
+	if state.job != nil || state.Editing {
+		return
+	}
+	if state.Analyzing {
+		xboardsearchstart(state, SearchAnalyze, search.SearchLimits{Infinite: true})
+	} else if state.Board.ToMove == state.EngineSide {
+		if claim := XboardDrawClaim(state.Board); claim != "" {
+			/* The opponent walked into a draw */
+			xboardresult(state, claim)
+			state.EngineSide = chess.FORCE
+			return
+		}
+		xboardsearchstart(state, SearchThink, XboardLimits(state))
+	}
+}
+
+func xboardsearchstart(state *XboardState, kind byte, limits search.SearchLimits) {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &xboardsearch{kind: kind, cancel: cancel,
+		done: make(chan struct{}), position: *state.Board}
+	job.position.History = nil
+	state.job = job
+	board, engine := state.Board, state.Engine
+	go func() {
+		job.result = engine.Search(ctx, board, limits, func(info search.Info) {
+			XboardThinking(board, info)
+		})
+		close(job.done)
+	}()
+}
+
+/* XboardThinking prints each finished iteration of a search of board as
+ * thinking output: ply, score, time in centiseconds, nodes and the pv in
+ * SAN. Mates are given the way xboard expects, as 100000 plus the number of
+ * moves. */
+func XboardThinking(board *chess.Board, info search.Info) {
+	if info.Kind != search.InfoIteration {
+		return
+	}
+	score := info.Score
+	if info.ScoreType == search.ScoreMate {
+		if info.Mate > 0 {
+			score = 100000 + info.Mate
+		} else {
+			score = -100000 + info.Mate
+		}
+	}
+	fmt.Println(info.Depth, score, int64(info.Time/time.Millisecond)/10,
+		info.Nodes, chess.SANLine(board, info.PV))
+}
+
+/* xboardsearchdone is the channel to wait on for the search to finish, or
+ * nil - which never fires - if there is nothing left to wait for. */
+func xboardsearchdone(state *XboardState) chan struct{} {
+	if state.job == nil || state.job.finished {
+		return nil
+	}
+	return state.job.done
+}
+
+/* xboardfinished deals with a search that has come to an end by itself. A
+ * ponder or analyze search just sits there until the next command. */
+func xboardfinished(state *XboardState) {
+	job := state.job
+	job.finished = true
+	if job.timer != nil {
+		job.timer.Stop()
+	}
+	job.cancel()
+	if job.kind != SearchThink {
+		return
+	}
+	state.job = nil
+	xboardplay(state, job.result)
+	xboardflushqueue(state)
+}
+
+/* xboardflushqueue deals with the commands that were waiting for the
+ * engine's move, now that it has been made or will not be. */
+func xboardflushqueue(state *XboardState) {
+	queue := state.queue
+	state.queue = nil
+	for _, input := range queue {
+		XboardCommand(state, input)
+	}
+}
+
+/* xboardstopsearch stops the search in progress and forgets about it. If we
+ * were pondering, the guessed move is taken back; if we were thinking, the
+ * commands waiting for the move are answered now. */
+func xboardstopsearch(state *XboardState) {
+	job := state.job
+	if job == nil {
+		return
+	}
+	job.cancel()
+	<-job.done
+	if job.timer != nil {
+		job.timer.Stop()
+	}
+	state.job = nil
+	if job.kind == SearchPonder {
+		state.Game.Undo()
+	}
+	xboardflushqueue(state)
+}
+
+/* XboardLimits works out the limits for the engine's next search. With nps
+ * set, the time we would have had becomes a node count. */
+func XboardLimits(state *XboardState) search.SearchLimits {
+	limits := state.Limits
+	if state.NPS > 0 {
+		allot := state.Engine.AllotTime(state.Board, limits)
+		limits.Nodes = uint64(allot.Seconds() * float64(state.NPS))
+		if limits.Nodes == 0 {
+			limits.Nodes = 1
+		}
+		limits.Infinite = true
+	}
+	return limits
+}
+
+/* xboardplay plays the first move of the pv the engine's search came up
+ * with, then starts pondering on the rest if we're allowed to. */
+func xboardplay(state *XboardState, result search.Info) {
+	pv := result.PV
+	if len(pv) == 0 {
+		/* No moves: mated, or stalemated and it's a draw */
+		if !chess.InCheck(state.Board) {
+			xboardresult(state, "1/2-1/2 {Stalemate}")
+		} else {
+			fmt.Println("resign")
+			if state.Board.ToMove == chess.WHITE {
+				state.Game.Result = "0-1"
+			} else {
+				state.Game.Result = "1-0"
+			}
+		}
+		state.EngineSide = chess.FORCE
+		return
+	}
+	move := pv[0]
+	xboardplayers(state, state.Board.ToMove)
+	xboardmake(state, &move, state.Engine.Clock)
+	state.Game.Plies[state.Game.Current-1].Comment = XboardComment(result)
+	fmt.Println("move", chess.MoveToLongAlgebraic(&move))
+	if claim := XboardDrawClaim(state.Board); claim != "" {
+		xboardresult(state, claim)
+		return
+	}
+	if state.Ponder && state.NPS == 0 {
+		xboardponder(state, pv[1:])
+	}
+}
+
+/* xboardponder guesses the opponent's reply - from the pv if it goes that
+ * far, otherwise from the hash table - makes it, and searches the position
+ * after it in the background. */
+func xboardponder(state *XboardState, pv []chess.Move) {
+	board := state.Board
+	var guess *chess.Move
+	if len(pv) > 0 {
+		guess = &pv[0]
+	} else if entry, ok := state.Engine.TT.Probe(board.Hash); ok && entry.Move.From != chess.INVALID {
+		guess = &entry.Move
+	} else {
+		return
+	}
+	move, err := chess.ParseMove(board, chess.MoveToLongAlgebraic(guess))
+	if err != nil {
+		return
+	}
+	state.Game.Play(move)
+	if chess.CountLegal(board) == 0 || XboardDrawClaim(board) != "" {
+		/* Nothing to think about if the game would be over */
+		state.Game.Undo()
+		return
+	}
+	state.pondermove = *move
+	fmt.Println("# pondering on", chess.MoveToLongAlgebraic(move))
+	limits := XboardLimits(state)
+	limits.Infinite = true
+	xboardsearchstart(state, SearchPonder, limits)
+}
+
+/* xboardponderhit keeps the ponder search going as the real one. Our clock
+ * only started running when the opponent moved, so the search gets the
+ * time AllotTime gives it from now, on top of what it has already had. */
+func xboardponderhit(state *XboardState) {
+	state.Game.SetClock(state.Engine.OppClock)
+	job := state.job
+	job.kind = SearchThink
+	/* If it already got to the end, the select picks it up at once */
+	job.finished = false
+	allot := state.Engine.AllotTime(&job.position, state.Limits)
+	fmt.Println("# ponder hit: allocated", allot)
+	job.timer = time.AfterFunc(allot, job.cancel)
+}
+
+/* xboardstat01 answers the . command with the progress of the analysis. */
+func xboardstat01(state *XboardState) string {
+	if state.job == nil || state.job.kind != SearchAnalyze {
+		return ""
+	}
+	s := state.Engine.Status()
+	return fmt.Sprintf("stat01: %d %d %d %d %d %s\n",
+		int64(time.Since(s.Start)/time.Millisecond)/10, s.Nodes, s.Depth,
+		s.RootMoves-s.RootDone, s.RootMoves,
+		chess.MoveToLongAlgebraic(&s.RootMove))
+}
+
+/* xboardmake plays move in the game, noting the mover's clock. */
+func xboardmake(state *XboardState, move *chess.Move, clock time.Duration) {
+	state.Game.Play(move)
+	state.Game.SetClock(clock)
+}
+
+/* xboardundo takes back the last n moves, if there are that many. */
+func xboardundo(state *XboardState, n int, command string) string {
+	if n > state.Game.Current {
+		return fmt.Sprintf("Error (no moves to undo): %s\n", command)
+	}
+	for i := 0; i < n; i++ {
+		state.Game.Undo()
+	}
+	state.Game.Result = "*"
+	state.logged = false
+	return ""
+}
+
+/* xboardsetboard starts a new game from board. */
+func xboardsetboard(state *XboardState, board *chess.Board) {
+	state.Game = chess.NewGame(board)
+	state.Game.Tags["Date"] = time.Now().Format("2006.01.02")
+	state.Board = state.Game.Board
+	state.logged = false
+}
+
+/* xboardplayers fills in who has which side, now that we know the engine
+ * is playing side. */
+func xboardplayers(state *XboardState, side byte) {
+	us, them := "White", "Black"
+	if side == chess.BLACK {
+		us, them = them, us
+	}
+	state.Game.Tags[us] = "Kusanagi"
+	if state.Opponent != "" {
+		state.Game.Tags[them] = state.Opponent
+	}
+}
+
+/* XboardComment is what the engine has to say about the move it found in
+ * a search: the score from its point of view in pawns, or +M and -M and the
+ * moves to mate, the depth, and the seconds it took. */
+func XboardComment(result search.Info) string {
+	score := fmt.Sprintf("%+.2f", float64(result.Score)/100)
+	if result.ScoreType == search.ScoreMate && result.Mate > 0 {
+		score = fmt.Sprintf("+M%d", result.Mate)
+	} else if result.ScoreType == search.ScoreMate {
+		score = fmt.Sprintf("-M%d", -result.Mate)
+	}
+	return fmt.Sprintf("%s/%d %.1f", score, result.Depth, result.Time.Seconds())
+}
+
+/* XboardTimeControl gives the time control set by level in the form of a
+ * PGN TimeControl tag, or ? if there isn't one. */
+func XboardTimeControl(engine *search.Engine) string {
+	seconds := int64(engine.TimePerTC / time.Second)
+	switch {
+	case engine.TimePerTC == 0:
+		return "?"
+	case engine.TimeRepeat > 0:
+		return fmt.Sprintf("%d/%d", engine.TimeRepeat, seconds)
+	}
+	return fmt.Sprintf("%d+%g", seconds, engine.TimeInc.Seconds())
+}
+
+/* xboardlog appends the game that has just finished to the PGN log, with
+ * the reason for the result from the result command after the last move. */
+func xboardlog(state *XboardState, reason string) {
+	if state.PGNLog == "" || state.logged || state.Game.Current == 0 {
+		return
+	}
+	state.logged = true
+	game := pgn.FromGame(state.Game)
+	game.SetTag("Event", "Computer Chess Game")
+	if host, err := os.Hostname(); err == nil {
+		game.SetTag("Site", host)
+	}
+	game.SetTag("Round", "-")
+	game.SetTag("TimeControl", XboardTimeControl(state.Engine))
+	if reason != "" {
+		last := game.Moves[len(game.Moves)-1]
+		last.Comment = strings.TrimSpace(last.Comment + " " + reason)
+	}
+	file, err := os.OpenFile(state.PGNLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err == nil {
+		err = pgn.Write(file, game)
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Println("# couldn't log the game:", err)
+	}
+}
+
+/* xboardresult prints a result line such as a draw claim, and records the
+ * result in the game. */
+func xboardresult(state *XboardState, line string) {
+	fmt.Println(line)
+	state.Game.Result = strings.Fields(line)[0]
+}
+
+/* xboardtestsuite runs the positions of an EPD file through the engine with
+ * the limits set by sd or st, printing how it does on each. */
+func xboardtestsuite(state *XboardState, path string) string {
+	if state.Limits.Depth == 0 && state.Limits.MoveTime == 0 {
+		return xboarderror("set a limit with sd or st first", "testsuite")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return xboarderror(err.Error(), "testsuite")
+	}
+	suite, err := chess.ReadEPD(file)
+	file.Close()
+	if err != nil {
+		return xboarderror(err.Error(), "testsuite")
+	}
+	start := time.Now()
+	solved := state.Engine.RunSuite(context.Background(), suite, state.Limits,
+		func(result search.SuiteResult) {
+			fmt.Println(XboardSuiteResult(result))
+		})
+	return fmt.Sprintf("Solved %d of %d in %s\n", solved, len(suite), time.Since(start))
+}
+
+/* XboardSuiteResult is a line saying how the engine did on a position of a
+ * test suite: what it was after, the move found, and its score, depth and
+ * time. */
+func XboardSuiteResult(result search.SuiteResult) string {
+	position := result.Position
+	id := position.Op("id")
+	if id == "" {
+		id = chess.ToFEN(position.Board)
+	}
+	status := "FAIL"
+	if result.Err != nil {
+		status = "error: " + result.Err.Error()
+	} else if result.Solved {
+		status = "ok"
+	}
+	var expected []string
+	for _, opcode := range []string{"bm", "am", "dm", "ce", "acd"} {
+		if operands := position.Op(opcode); operands != "" {
+			expected = append(expected, opcode+" "+operands)
+		}
+	}
+	found := "none"
+	if len(result.Info.PV) > 0 {
+		found = chess.MoveToSAN(position.Board, &result.Info.PV[0])
+	}
+	return fmt.Sprintf("%s: %s (%s) %s {%s}", id, status,
+		strings.Join(expected, "; "), found, XboardComment(result.Info))
+}
+
+/*
+xboardperftsuite checks Perft against the D1, D2 and so on operations of each
+position in an EPD file, up to maxdepth. A position stops being checked at
+the first depth it gets wrong, and the first wrong one of all is broken down
+with divide. At the end come the totals, and the speed.
+*/
+func xboardperftsuite(path string, maxdepth int) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return xboarderror(err.Error(), "perftsuite")
+	}
+	suite, err := chess.ReadEPD(file)
+	file.Close()
+	if err != nil {
+		return xboarderror(err.Error(), "perftsuite")
+	}
+	var nodes uint64
+	var elapsed time.Duration
+	failed, divided := 0, false
+	for i, position := range suite {
+		report := fmt.Sprintf("%d %s:", i+1, chess.ToFEN(position.Board))
+		for depth := 1; depth <= maxdepth; depth++ {
+			want, err := position.Int(fmt.Sprintf("D%d", depth))
+			if err != nil {
+				continue
+			}
+			start := time.Now()
+			got := chess.Perft(depth, position.Board, false)
+			elapsed += time.Since(start)
+			nodes += got
+			if got == uint64(want) {
+				report += fmt.Sprintf(" D%d ok", depth)
+				continue
+			}
+			report += fmt.Sprintf(" D%d FAILURE: %d, not %d", depth, got, want)
+			failed++
+			if !divided {
+				fmt.Println(report)
+				fmt.Println("# divide at depth", depth)
+				chess.Perft(depth, position.Board, true)
+				divided = true
+				report = ""
+			}
+			break
+		}
+		if report != "" {
+			fmt.Println(report)
+		}
+	}
+	var nps uint64
+	if elapsed > 0 {
+		nps = nodes * uint64(time.Second) / uint64(elapsed)
+	}
+	return fmt.Sprintf("%d positions, %d failed; %d nodes in %s, %d nps\n",
+		len(suite), failed, nodes, elapsed, nps)
+}
+
+func xboarderror(kind, command string) string {
+	return fmt.Sprintf("Error (%s): %s\n", kind, command)
+}
+
+/* XboardDrawClaim gives the result line claiming a draw by rule in the
+ * current position, or "" if there's nothing to claim. */
+func XboardDrawClaim(board *chess.Board) string {
+	if chess.IsThreefold(board) {
+		return "1/2-1/2 {Draw by repetition}"
+	} else if chess.IsFiftyMoves(board) {
+		return "1/2-1/2 {Draw by fifty move rule}"
+	}
+	return ""
+}
+
+/* xboardedit handles a command in edit mode. */
+func xboardedit(state *XboardState, line string) string {
+	board := state.EditBoard
+	switch line {
+	case "#":
+		tomove := board.ToMove
+		chess.ClearBoard(board)
+		board.ToMove = tomove
+		return ""
+	case "c":
+		state.EditColor ^= chess.BLACK
+		return ""
+	case ".":
+		/* Castling is allowed wherever the king and rook are still
+		 * at home. */
+		board.Castle = 0
+		home := []struct {
+			king, rook  string
+			side, right byte
+		}{{"e1", "h1", chess.WHITE, chess.CASTLEWK}, {"e1", "a1", chess.WHITE, chess.CASTLEWQ},
+			{"e8", "h8", chess.BLACK, chess.CASTLEBK}, {"e8", "a8", chess.BLACK, chess.CASTLEBQ}}
+		for _, h := range home {
+			king, _ := chess.AlgebraicToIndex(h.king)
+			rook, _ := chess.AlgebraicToIndex(h.rook)
+			if board.Data[king] == h.side|chess.KING &&
+				board.Data[rook] == h.side|chess.ROOK {
+				board.Castle |= h.right
+			}
+		}
+		board.EnPassant = chess.INVALID
+		state.Editing = false
+		state.EditBoard = nil
+		edited, err := chess.Parse(chess.ToFEN(board))
+		if err == nil {
+			err = chess.Validate(edited)
+		}
+		if err != nil {
+			return "tellusererror Illegal position\n"
+		}
+		xboardsetboard(state, edited)
+		return ""
+	}
+	if len(line) != 3 {
+		return xboarderror("bad edit command", line)
+	}
+	sq, err := chess.AlgebraicToIndex(line[1:])
+	if err != nil {
+		return xboarderror("bad square", line)
+	}
+	piece := chess.EMPTY
+	switch line[0] {
+	case 'P':
+		piece = chess.PAWN
+	case 'N':
+		piece = chess.KNIGHT
+	case 'B':
+		piece = chess.BISHOP
+	case 'R':
+		piece = chess.ROOK
+	case 'Q':
+		piece = chess.QUEEN
+	case 'K':
+		piece = chess.KING
+	case 'x', 'X':
+		board.Data[sq] = chess.EMPTY
+		return ""
+	default:
+		return xboarderror("bad piece", line)
+	}
+	board.Data[sq] = state.EditColor | piece
+	return ""
+}
 
-func XboardParse(line string, board *Board, verbose bool, engine_side *byte) (*Board, string) {
-	if verbose {
+func XboardParse(line string, state *XboardState) string {
+	if state.Verbose {
 		log.Println(line)
 	}
+	if state.Editing {
+		return xboardedit(state, line)
+	}
+	board := state.Board
 	words := strings.Split(line, " ")
 	if len(words) == 0 {
-		return board, "\n"
+		return "\n"
 	}
 	switch words[0] {
 	case "perft":
 		if len(words) > 2 {
 			depth, err := strconv.Atoi(words[1])
 			if err != nil {
-				return board, err.Error()
+				return xboarderror(err.Error(), line)
 			}
 			var expected uint64
 			expected, err = strconv.ParseUint(words[2], 10, 64)
 			if err != nil {
-				return board, err.Error()
+				return xboarderror(err.Error(), line)
 			}
-			if Perft(depth, board, false) == expected {
-				return board, "SUCCESS\n"
+			if chess.Perft(depth, board, false) == expected {
+				return "SUCCESS\n"
 			} else {
-				return board, "FAILURE\n"
+				return "FAILURE\n"
 			}
 		}
 	case "divide":
@@ -41,58 +723,192 @@ func XboardParse(line string, board *Board, verbose bool, engine_side *byte) (*B
 			res, err := strconv.Atoi(words[1])
 			if err == nil {
 				start := time.Now()
-				nodes := Perft(res, board, true)
+				nodes := chess.Perft(res, board, true)
 				elapsed := time.Since(start)
 				log.Printf("Divide took %s", elapsed)
-				return board, strconv.FormatUint(nodes, 10) + "\n"
+				return strconv.FormatUint(nodes, 10) + "\n"
 			} else {
-				return board, err.Error()
+				return xboarderror(err.Error(), line)
+			}
+		}
+	case "perftsuite":
+		if len(words) > 1 {
+			maxdepth := 6
+			if len(words) > 2 {
+				depth, err := strconv.Atoi(words[2])
+				if err != nil {
+					return xboarderror("bad depth", line)
+				}
+				maxdepth = depth
 			}
+			return xboardperftsuite(words[1], maxdepth)
+		}
+	case "testsuite":
+		if len(words) > 1 {
+			return xboardtestsuite(state, strings.TrimPrefix(line, "testsuite "))
 		}
 	case "setboard":
-		board, _ = Parse(strings.TrimPrefix(line, "setboard "))
+		newboard, err := chess.Parse(strings.TrimPrefix(line, "setboard "))
+		if err == nil {
+			err = chess.Validate(newboard)
+		}
+		if err != nil {
+			/* Keep the old board so we stay in step with the GUI */
+			return "tellusererror Illegal position\n"
+		}
+		xboardsetboard(state, newboard)
 	case "new":
-		board, _ = Parse(START)
+		newboard, _ := chess.Parse(chess.START)
+		xboardsetboard(state, newboard)
+		state.EngineSide = chess.BLACK
+		state.Limits = search.SearchLimits{}
+		state.Engine.TT.Clear()
+	case "memory":
+		if len(words) > 1 {
+			mb, err := strconv.Atoi(words[1])
+			if err != nil {
+				return xboarderror(err.Error(), line)
+			}
+			state.Engine.ResizeHash(mb)
+		}
+	case "cores":
+		if len(words) > 1 {
+			cores, err := strconv.Atoi(words[1])
+			if err != nil {
+				return xboarderror("bad number", line)
+			}
+			/* We only search with one thread, so this is noted
+			 * and no more. */
+			state.Cores = cores
+		}
 	case "usermove":
 		if len(words) > 1 {
-			move, err := ParseMove(board, words[1])
-			if err == nil {
-				MakeMove(board, move)
-				board.Moves++
-			} else {
-				return board, err.Error()
+			move, err := chess.ParseMove(board, words[1])
+			if err != nil {
+				/* Typed in by hand, perhaps */
+				move, err = chess.ParseSAN(board, words[1])
+			}
+			if err != nil {
+				return fmt.Sprintf("Illegal move: %s\n", words[1])
 			}
+			xboardmake(state, move, state.Engine.OppClock)
 		}
+	case "undo":
+		return xboardundo(state, 1, line)
+	case "remove":
+		return xboardundo(state, 2, line)
 	case "go":
-		*engine_side = board.ToMove
-		return board, ""
+		state.EngineSide = board.ToMove
+		return ""
+	case "playother":
+		state.EngineSide = board.ToMove ^ chess.BLACK
+		return ""
+	case "white":
+		/* Obsolete, but some interfaces still send them */
+		chess.SetToMove(board, chess.WHITE)
+		state.EngineSide = chess.BLACK
+	case "black":
+		chess.SetToMove(board, chess.BLACK)
+		state.EngineSide = chess.WHITE
 	case "force":
-		*engine_side = FORCE
-		return board, ""
+		state.EngineSide = chess.FORCE
+		return ""
+	case "result":
+		if len(words) > 1 {
+			state.Game.Result = words[1]
+			reason := strings.TrimPrefix(strings.Join(words[2:], " "), "{")
+			xboardlog(state, strings.TrimSuffix(reason, "}"))
+		}
+		state.EngineSide = chess.FORCE
+		return ""
+	case "?":
+		/* Not thinking, so nothing to hurry */
+	case "analyze":
+		state.Analyzing = true
+		state.EngineSide = chess.FORCE
+	case "exit":
+		state.Analyzing = false
+	case ".":
+		return xboardstat01(state)
+	case "edit":
+		state.Editing = true
+		state.EditColor = chess.WHITE
+		state.EditBoard, _ = chess.Parse(chess.ToFEN(board))
 	case "d":
-		return board, PrintBoard(board)
+		return chess.PrintBoard(board)
+	case "fen":
+		return chess.ToFEN(board) + "\n"
 	case "protover":
-		return board, XBOARDFEATURES
-	case "xboard", "post", "nopost", "random":
-		return board, ""
+		return XBOARDFEATURES
+	case "xboard", "post", "nopost", "random", "accepted", "rejected":
+		return ""
+	case "draw":
+		/* We said draw=0, but in case it comes anyway: no thanks */
+		return ""
+	case "hard":
+		state.Ponder = true
+	case "easy":
+		state.Ponder = false
+	case "name":
+		state.Opponent = strings.TrimPrefix(line, "name ")
+	case "computer":
+		state.Computer = true
+	case "rating":
+		if len(words) > 2 {
+			state.Rating, _ = strconv.Atoi(words[1])
+			state.OppRate, _ = strconv.Atoi(words[2])
+		}
 	case "ping":
 		if len(words) > 1 {
-			return board, fmt.Sprintf("pong %s\n", words[1])
+			return fmt.Sprintf("pong %s\n", words[1])
+		}
+	case "sd":
+		if len(words) > 1 {
+			depth, err := strconv.Atoi(words[1])
+			if err != nil {
+				return xboarderror("bad depth", line)
+			}
+			state.Limits.Depth = depth
+		}
+	case "st":
+		if len(words) > 1 {
+			seconds, err := strconv.ParseFloat(words[1], 64)
+			if err != nil {
+				return xboarderror("bad time", line)
+			}
+			state.Limits.MoveTime = time.Duration(seconds * float64(time.Second))
+		}
+	case "nps":
+		if len(words) > 1 {
+			nps, err := strconv.Atoi(words[1])
+			if err != nil {
+				return xboarderror("bad number", line)
+			}
+			state.NPS = nps
 		}
 	case "time":
 		if len(words) > 1 {
 			duration, err := time.ParseDuration(words[1] + "0ms")
 			if err == nil {
-				Clock = duration
+				state.Engine.Clock = duration
+			} else {
+				return xboarderror(err.Error(), line)
+			}
+		}
+	case "otim":
+		if len(words) > 1 {
+			duration, err := time.ParseDuration(words[1] + "0ms")
+			if err == nil {
+				state.Engine.OppClock = duration
 			} else {
-				return board, err.Error()
+				return xboarderror(err.Error(), line)
 			}
 		}
 	case "level":
 		if len(words) > 3 {
 			tr, err := strconv.Atoi(words[1])
 			if err != nil {
-				return board, err.Error()
+				return xboarderror(err.Error(), line)
 			}
 			var tptc time.Duration
 			tptcSpl := strings.Split(words[2], ":")
@@ -101,25 +917,29 @@ func XboardParse(line string, board *Board, verbose bool, engine_side *byte) (*B
 					tptcSpl[1])
 				tptc, err = time.ParseDuration(dur)
 				if err != nil {
-					return board, err.Error()
+					return xboarderror(err.Error(), line)
 				}
 			} else {
 				tptc, err = time.ParseDuration(words[2] + "m")
 				if err != nil {
-					return board, err.Error()
+					return xboarderror(err.Error(), line)
 				}
 			}
 			var ti time.Duration
 			ti, err = time.ParseDuration(words[3] + "s")
 			if err != nil {
-				return board, err.Error()
+				return xboarderror(err.Error(), line)
 			}
-			TimeRepeat = tr
-			TimePerTC = tptc
-			TimeInc = ti
-			Clock = tptc
-			return board, fmt.Sprintln("#", tr, tptc, ti)
+			state.Engine.TimeRepeat = tr
+			state.Engine.TimePerTC = tptc
+			state.Engine.TimeInc = ti
+			state.Engine.Clock = tptc
+			/* level and st replace each other */
+			state.Limits.MoveTime = 0
+			return fmt.Sprintln("#", tr, tptc, ti)
 		}
+	default:
+		return xboarderror("unknown command", words[0])
 	}
-	return board, ""
+	return ""
 }
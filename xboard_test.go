@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestXboardPingAfterAbandonedThink(t *testing.T) {
+	state := NewXboardState(false)
+	out := captureoutput(t, func() {
+		for _, input := range []string{"new", "st 5", "go"} {
+			XboardCommand(state, input)
+		}
+		xboardstartsearch(state)
+		/* Held back until the move... */
+		XboardCommand(state, "ping 7")
+		/* ...which now never comes */
+		XboardCommand(state, "force")
+	})
+	if !strings.Contains(out, "pong 7\n") || strings.Contains(out, "move ") {
+		t.Error(out)
+	}
+	if state.job != nil || len(state.queue) != 0 {
+		t.Error("search or queue left over")
+	}
+}
+
+func TestXboardDrawOfferKeepsThinking(t *testing.T) {
+	state := NewXboardState(false)
+	out := captureoutput(t, func() {
+		for _, input := range []string{"new", "st 5", "go"} {
+			XboardCommand(state, input)
+		}
+		xboardstartsearch(state)
+		job := state.job
+		for _, input := range []string{"draw", "easy", "bogus", "otim 1000"} {
+			XboardCommand(state, input)
+			if state.job != job {
+				t.Error(input, "stopped the search")
+			}
+		}
+		XboardCommand(state, "force")
+	})
+	if !strings.Contains(out, "Error (unknown command): bogus") {
+		t.Error(out)
+	}
+}